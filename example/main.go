@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -55,6 +56,11 @@ func main() {
 	simulateBusinessLogic()
 
 	log.Info("应用程序结束")
+
+	// 退出前推送队列中的日志并关闭写入器
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = log.Shutdown(ctx)
 }
 
 // simulateBusinessLogic 模拟业务逻辑产生的日志
@@ -0,0 +1,432 @@
+package log
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 检查点签名算法
+const (
+	AuditAlgHMACSHA256 = "hmac-sha256"
+	AuditAlgEd25519    = "ed25519"
+)
+
+// AuditConfig 防篡改审计模式配置
+// 每行带序号和上一行的SHA-256，从每个文件的创世记录开始形成哈希链
+// CheckpointEvery/CheckpointInterval: 每N条记录或每隔一段时间写入签名检查点，均为0时不写
+// HMACKey/SigningKey: 检查点签名密钥，二选一，SigningKey优先
+type AuditConfig struct {
+	CheckpointEvery    int                // 每N条记录写检查点
+	CheckpointInterval time.Duration      // 每隔一段时间写检查点
+	HMACKey            []byte             // HMAC-SHA256密钥
+	SigningKey         ed25519.PrivateKey // Ed25519私钥
+}
+
+// VerifyKeys 校验检查点签名所需的密钥
+// KeyProvider用于校验加密的审计文件
+type VerifyKeys struct {
+	HMACKey     []byte            // HMAC-SHA256密钥
+	PublicKey   ed25519.PublicKey // Ed25519公钥
+	KeyProvider KeyProvider       // 解密密钥提供者
+}
+
+// auditLine 审计文件中的一行
+type auditLine struct {
+	Seq        uint64           `json:"seq"`
+	Prev       string           `json:"prev"`
+	Genesis    *auditGenesis    `json:"genesis,omitempty"`
+	Entry      json.RawMessage  `json:"entry,omitempty"`
+	Checkpoint *auditCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// auditGenesis 创世记录，PrevFile为轮转时的历史文件名，Prev即其最后一行的哈希
+// 开启Compress时历史文件随后被压缩，查找时需同时尝试PrevFile与PrevFile+".gz"
+type auditGenesis struct {
+	File     string `json:"file"`
+	Created  int64  `json:"created"`
+	Nonce    string `json:"nonce"`
+	PrevFile string `json:"prev_file,omitempty"`
+}
+
+// auditCheckpoint 签名检查点，签名内容见checkpointMessage
+type auditCheckpoint struct {
+	Alg string `json:"alg"`
+	Sig string `json:"sig"`
+}
+
+// ChainError 哈希链校验失败，指出第一个断裂的位置
+type ChainError struct {
+	Path   string // 文件路径
+	Line   int    // 行号（从1开始）
+	Seq    uint64 // 该行声明的序号
+	Reason string // 断裂原因
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken in %s at line %d (seq %d): %s", e.Path, e.Line, e.Seq, e.Reason)
+}
+
+// auditChain 写入端的哈希链状态
+type auditChain struct {
+	cfg             AuditConfig
+	seq             uint64
+	prev            [32]byte
+	sinceCheckpoint int
+	lastCheckpoint  time.Time
+	prevFile        string
+	unsigned        bool // 最后一行之后尚无检查点
+}
+
+func newAuditChain(c AuditConfig) *auditChain {
+	return &auditChain{cfg: c, lastCheckpoint: time.Now()}
+}
+
+// resume 从已有文件末行恢复链状态，last为nil（新文件）时返回创世记录
+func (a *auditChain) resume(path string, last []byte) ([]byte, error) {
+	if last == nil {
+		return a.genesis(path)
+	}
+	var l auditLine
+	if err := json.Unmarshal(last, &l); err != nil || l.Prev == "" {
+		return nil, fmt.Errorf("file %s is not an audit log", path)
+	}
+	a.seq = l.Seq
+	a.prev = sha256.Sum256(last)
+	a.unsigned = l.Checkpoint == nil
+	return nil, nil
+}
+
+// recoverTorn 记录恢复时丢弃的不完整末行（崩溃时写了一半），返回一条说明记录
+func (a *auditChain) recoverTorn(torn []byte) ([]byte, error) {
+	sum := sha256.Sum256(torn)
+	entry, err := json.Marshal(map[string]interface{}{
+		"level":       "warn",
+		"message":     "discarded torn audit record",
+		"torn_bytes":  len(torn),
+		"torn_sha256": hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.record(entry)
+}
+
+// seal 链尾尚无检查点时补写一个，关闭与轮转前调用，未配置密钥时返回nil
+func (a *auditChain) seal() ([]byte, error) {
+	if !a.unsigned || (a.cfg.SigningKey == nil && a.cfg.HMACKey == nil) {
+		return nil, nil
+	}
+	return a.checkpoint()
+}
+
+// genesis 生成新文件的创世记录，prev沿用上一个文件最后一行的哈希
+func (a *auditChain) genesis(path string) ([]byte, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	a.seq = 0
+	a.unsigned = true
+	line, err := json.Marshal(auditLine{
+		Seq:  0,
+		Prev: hex.EncodeToString(a.prev[:]),
+		Genesis: &auditGenesis{
+			File:     filepath.Base(path),
+			Created:  time.Now().Unix(),
+			Nonce:    hex.EncodeToString(nonce),
+			PrevFile: a.prevFile,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.link(line), nil
+}
+
+// record 将编码后的日志条目加入哈希链，必要时追加检查点
+func (a *auditChain) record(entry []byte) ([]byte, error) {
+	a.seq++
+	line, err := json.Marshal(auditLine{
+		Seq:   a.seq,
+		Prev:  hex.EncodeToString(a.prev[:]),
+		Entry: json.RawMessage(entry),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := a.link(line)
+	a.sinceCheckpoint++
+	a.unsigned = true
+
+	if a.checkpointDue() {
+		cp, err := a.checkpoint()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cp...)
+	}
+	return out, nil
+}
+
+func (a *auditChain) checkpointDue() bool {
+	if a.cfg.SigningKey == nil && a.cfg.HMACKey == nil {
+		return false
+	}
+	if a.cfg.CheckpointEvery > 0 && a.sinceCheckpoint >= a.cfg.CheckpointEvery {
+		return true
+	}
+	return a.cfg.CheckpointInterval > 0 && time.Since(a.lastCheckpoint) >= a.cfg.CheckpointInterval
+}
+
+// checkpoint 对当前链头签名
+func (a *auditChain) checkpoint() ([]byte, error) {
+	a.seq++
+	prev := hex.EncodeToString(a.prev[:])
+	msg := checkpointMessage(a.seq, prev)
+
+	cp := &auditCheckpoint{}
+	if a.cfg.SigningKey != nil {
+		cp.Alg = AuditAlgEd25519
+		cp.Sig = hex.EncodeToString(ed25519.Sign(a.cfg.SigningKey, msg))
+	} else {
+		cp.Alg = AuditAlgHMACSHA256
+		cp.Sig = hex.EncodeToString(hmacSHA256(a.cfg.HMACKey, msg))
+	}
+
+	line, err := json.Marshal(auditLine{Seq: a.seq, Prev: prev, Checkpoint: cp})
+	if err != nil {
+		return nil, err
+	}
+	a.sinceCheckpoint = 0
+	a.lastCheckpoint = time.Now()
+	a.unsigned = false
+	return a.link(line), nil
+}
+
+// link 更新链头并返回带换行的行
+func (a *auditChain) link(line []byte) []byte {
+	a.prev = sha256.Sum256(line)
+	return append(line, '\n')
+}
+
+func checkpointMessage(seq uint64, prev string) []byte {
+	return []byte(strconv.FormatUint(seq, 10) + ":" + prev)
+}
+
+func hmacSHA256(key, msg []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(msg)
+	return m.Sum(nil)
+}
+
+// truncateTornLine 截掉文件末尾没有换行符的不完整行，返回被截掉的内容
+func truncateTornLine(path string, size int64) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	const chunk = 64 * 1024
+	var tail []byte
+	for off := size; off > 0; {
+		n := int64(chunk)
+		if off < n {
+			n = off
+		}
+		off -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		i := strings.LastIndexByte(string(tail), '\n')
+		if i == len(tail)-1 {
+			return nil, nil
+		}
+		if i >= 0 || off == 0 {
+			torn := tail[i+1:]
+			return torn, f.Truncate(size - int64(len(torn)))
+		}
+	}
+	return nil, nil
+}
+
+// readLastLine 读取文件最后一个非空行
+func readLastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+
+	const chunk = 64 * 1024
+	var tail []byte
+	for off := size; off > 0; {
+		n := int64(chunk)
+		if off < n {
+			n = off
+		}
+		off -= n
+		buf := make([]byte, n)
+		if _, err := f.ReadAt(buf, off); err != nil {
+			return nil, err
+		}
+		tail = append(buf, tail...)
+		trimmed := strings.TrimRight(string(tail), "\n")
+		if i := strings.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return []byte(trimmed[i+1:]), nil
+		}
+		if off == 0 {
+			return []byte(trimmed), nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// VerifyFile 校验审计日志文件的哈希链，返回第一个断裂处的*ChainError
+// 不校验检查点签名，只能发现局部修改，整体重算哈希链的文件仍能通过，防篡改应使用VerifyFileWithKeys
+func VerifyFile(path string) error {
+	return VerifyFileWithKeys(path, VerifyKeys{})
+}
+
+// VerifyFileWithKeys 校验哈希链，并用给定密钥校验检查点签名
+// 提供了任一密钥时，文件须至少有一个有效检查点且最后一行为检查点，
+// 算法没有对应密钥的检查点视为无效；.gz文件会自动解压
+func VerifyFileWithKeys(path string, keys VerifyKeys) error {
+	f, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if keys.KeyProvider != nil {
+		if r, err = NewDecryptReader(f, keys.KeyProvider); err != nil {
+			return err
+		}
+	}
+	return verifyChain(path, r, keys)
+}
+
+// verifyChain 逐行校验序号、前驱哈希与检查点签名
+func verifyChain(path string, r io.Reader, keys VerifyKeys) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	signing := keys.HMACKey != nil || keys.PublicKey != nil
+	var prev [32]byte
+	var seq uint64
+	lineNo, records, signed, unsigned := 0, 0, 0, 0
+	for sc.Scan() {
+		lineNo++
+		raw := sc.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		records++
+		broken := func(seq uint64, reason string) error {
+			return &ChainError{Path: path, Line: lineNo, Seq: seq, Reason: reason}
+		}
+
+		var l auditLine
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return broken(0, "malformed line: "+err.Error())
+		}
+
+		if records == 1 {
+			if l.Genesis == nil || l.Seq != 0 {
+				return broken(l.Seq, "missing genesis record")
+			}
+		} else {
+			if l.Genesis != nil {
+				return broken(l.Seq, "unexpected genesis record")
+			}
+			if l.Seq != seq+1 {
+				return broken(l.Seq, fmt.Sprintf("expected seq %d", seq+1))
+			}
+			if l.Prev != hex.EncodeToString(prev[:]) {
+				return broken(l.Seq, "previous hash mismatch")
+			}
+			if l.Checkpoint == nil && len(l.Entry) == 0 {
+				return broken(l.Seq, "empty record")
+			}
+		}
+
+		if l.Checkpoint != nil {
+			if err := verifyCheckpoint(l, keys); err != nil {
+				return broken(l.Seq, err.Error())
+			}
+			signed++
+			unsigned = 0
+		} else {
+			unsigned++
+		}
+
+		seq = l.Seq
+		prev = sha256.Sum256(raw)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if records == 0 {
+		return &ChainError{Path: path, Reason: "empty file"}
+	}
+	if signing && signed == 0 {
+		return &ChainError{Path: path, Line: lineNo, Seq: seq, Reason: "no signed checkpoint"}
+	}
+	if signing && unsigned > 0 {
+		return &ChainError{Path: path, Line: lineNo, Seq: seq,
+			Reason: fmt.Sprintf("%d records after the last checkpoint are not signed", unsigned)}
+	}
+	return nil
+}
+
+func verifyCheckpoint(l auditLine, keys VerifyKeys) error {
+	sig, err := hex.DecodeString(l.Checkpoint.Sig)
+	if err != nil {
+		return errors.New("malformed checkpoint signature")
+	}
+	msg := checkpointMessage(l.Seq, l.Prev)
+	signing := keys.HMACKey != nil || keys.PublicKey != nil
+	switch l.Checkpoint.Alg {
+	case AuditAlgEd25519:
+		if keys.PublicKey == nil {
+			if signing {
+				return errors.New("no public key for ed25519 checkpoint")
+			}
+		} else if !ed25519.Verify(keys.PublicKey, msg, sig) {
+			return errors.New("invalid ed25519 checkpoint signature")
+		}
+	case AuditAlgHMACSHA256:
+		if keys.HMACKey == nil {
+			if signing {
+				return errors.New("no hmac key for hmac-sha256 checkpoint")
+			}
+		} else if !hmac.Equal(sig, hmacSHA256(keys.HMACKey, msg)) {
+			return errors.New("invalid hmac checkpoint signature")
+		}
+	default:
+		return fmt.Errorf("unknown checkpoint algorithm %q", l.Checkpoint.Alg)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package log
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeAuditEntries(t *testing.T, path string, c AuditConfig, n int) {
+	t.Helper()
+	fw, err := NewFileWriterWithConfig(FileWriterConfig{Path: path, Audit: &c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		entry := &LogEntry{Level: "info", Message: "audit event", Fields: map[string]interface{}{"i": i}, Time: time.Now().Unix()}
+		if err := fw.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditChainVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("secret")
+	c := AuditConfig{CheckpointEvery: 2, HMACKey: key}
+
+	writeAuditEntries(t, path, c, 3)
+	// 重新打开后应接续原有哈希链
+	writeAuditEntries(t, path, c, 2)
+
+	if err := VerifyFileWithKeys(path, VerifyKeys{HMACKey: key}); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if err := VerifyFileWithKeys(path, VerifyKeys{HMACKey: []byte("wrong")}); err == nil {
+		t.Error("expected checkpoint signature failure with wrong key")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(string(data), "\n")
+	lines[2] = strings.Replace(lines[2], "audit event", "edited event", 1)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var ce *ChainError
+	if err := VerifyFile(path); !errors.As(err, &ce) {
+		t.Fatalf("expected ChainError, got %v", err)
+	}
+	if ce.Line != 4 {
+		t.Errorf("first broken link at line %d, want 4", ce.Line)
+	}
+}
+
+func TestAuditEd25519Checkpoint(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeAuditEntries(t, path, AuditConfig{CheckpointEvery: 1, SigningKey: priv}, 2)
+
+	if err := VerifyFileWithKeys(path, VerifyKeys{PublicKey: pub}); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if err := VerifyFileWithKeys(path, VerifyKeys{PublicKey: otherPub}); err == nil {
+		t.Error("expected signature failure with another public key")
+	}
+}
+
+// rechain 按内容重算每行的序号与前驱哈希，模拟攻击者整体重写文件
+func rechain(t *testing.T, lines []string) string {
+	t.Helper()
+	var prev [32]byte
+	var out []string
+	for i, raw := range lines {
+		var l auditLine
+		if err := json.Unmarshal([]byte(raw), &l); err != nil {
+			t.Fatal(err)
+		}
+		l.Seq = uint64(i)
+		l.Prev = hex.EncodeToString(prev[:])
+		b, err := json.Marshal(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = sha256.Sum256(b)
+		out = append(out, string(b))
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+func readAuditLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+func TestAuditVerifyRejectsForgery(t *testing.T) {
+	key := []byte("secret")
+	keys := VerifyKeys{HMACKey: key}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "audit.log")
+	writeAuditEntries(t, src, AuditConfig{CheckpointEvery: 2, HMACKey: key}, 3)
+	lines := readAuditLines(t, src)
+
+	// 检查点改标为没有提供密钥的算法
+	var relabelled []string
+	for _, l := range lines {
+		if strings.Contains(l, `"checkpoint"`) {
+			l = strings.Replace(l, AuditAlgHMACSHA256, AuditAlgEd25519, 1)
+		}
+		relabelled = append(relabelled, l)
+	}
+	// 删除全部检查点后重算哈希链
+	var stripped []string
+	for _, l := range lines {
+		if !strings.Contains(l, `"checkpoint"`) {
+			stripped = append(stripped, l)
+		}
+	}
+	// 截掉关闭时补写的最后一个检查点
+	truncated := append([]string(nil), lines[:len(lines)-1]...)
+
+	for name, content := range map[string]string{
+		"relabelled": rechain(t, relabelled),
+		"stripped":   rechain(t, stripped),
+		"truncated":  strings.Join(truncated, "\n") + "\n",
+	} {
+		path := filepath.Join(dir, name+".log")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyFile(path); err != nil {
+			t.Errorf("%s: hash chain alone should still be consistent: %v", name, err)
+		}
+		var ce *ChainError
+		if err := VerifyFileWithKeys(path, keys); !errors.As(err, &ce) {
+			t.Errorf("%s: expected ChainError, got %v", name, err)
+		}
+	}
+}
+
+func TestAuditResumeTornLine(t *testing.T) {
+	key := []byte("secret")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	c := AuditConfig{CheckpointEvery: 10, HMACKey: key}
+	writeAuditEntries(t, path, c, 2)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":5,"prev":"ab`)
+	f.Close()
+
+	writeAuditEntries(t, path, c, 1)
+	if err := VerifyFileWithKeys(path, VerifyKeys{HMACKey: key}); err != nil {
+		t.Fatalf("verify after torn line: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"torn_bytes":19`) {
+		t.Errorf("torn line not recorded:\n%s", data)
+	}
+}
@@ -0,0 +1,280 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull 发送队列已满，日志被丢弃
+var ErrQueueFull = errors.New("log queue is full")
+
+// ErrWriterClosed 写入器已关闭
+var ErrWriterClosed = errors.New("log writer is closed")
+
+// BatchConfig 远程写入器的批量发送配置
+// Size: 每批最大条数，默认100
+// Interval: 未凑满一批时的最长等待时间，默认1秒
+// QueueSize: 待发送队列容量，默认10000，队列满时新日志被丢弃
+type BatchConfig struct {
+	Size      int           // 每批条数
+	Interval  time.Duration // 最长等待时间
+	QueueSize int           // 队列容量
+}
+
+// RetryConfig 远程写入器的重试配置
+// MaxRetries: 每批最多尝试次数，默认3
+// Backoff: 第i次失败后等待i*Backoff再重试，默认1秒
+type RetryConfig struct {
+	MaxRetries int           // 最多尝试次数
+	Backoff    time.Duration // 重试间隔基数
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.Size <= 0 {
+		c.Size = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 10000
+	}
+	return c
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = 3
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = time.Second
+	}
+	return c
+}
+
+// permanentError 不应重试的错误（如请求被服务端拒绝）
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// permanent 标记错误不再重试
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// partialError 一批日志中仅部分失败，重试时只重发failed中的日志
+type partialError struct {
+	failed []*LogEntry
+	err    error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
+
+// batcher 远程写入器共用的队列、批量与重试逻辑
+// Write只负责入队，后台协程按条数或时间凑批后调用push发送
+type batcher struct {
+	name  string // 写入器类型，用作指标的writer标签
+	cfg   BatchConfig
+	retry RetryConfig
+	push  func(batch []*LogEntry) error
+
+	queue   chan *LogEntry
+	flushCh chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	mu      sync.RWMutex // enqueue持读锁，close持写锁，保证关闭后不再有日志入队
+	closed  bool
+
+	abort     chan struct{} // close的ctx结束时关闭，停止重试等待并丢弃剩余日志
+	abortOnce sync.Once
+}
+
+// 运行中的batcher，用于采集队列深度
+var (
+	batchersMu sync.Mutex
+	batchers   = map[*batcher]bool{}
+)
+
+func newBatcher(name string, c BatchConfig, r RetryConfig, push func([]*LogEntry) error) *batcher {
+	c = c.withDefaults()
+	b := &batcher{
+		name:    name,
+		cfg:     c,
+		retry:   r.withDefaults(),
+		push:    push,
+		queue:   make(chan *LogEntry, c.QueueSize),
+		flushCh: make(chan chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		abort:   make(chan struct{}),
+	}
+	batchersMu.Lock()
+	batchers[b] = true
+	batchersMu.Unlock()
+	go b.run()
+	return b
+}
+
+// queueDepths 按写入器类型汇总各队列中待发送的日志数
+func queueDepths() map[string]float64 {
+	batchersMu.Lock()
+	defer batchersMu.Unlock()
+	depths := map[string]float64{}
+	for b := range batchers {
+		depths[b.name] += float64(len(b.queue))
+	}
+	return depths
+}
+
+// enqueue 非阻塞入队，关闭后返回ErrWriterClosed
+func (b *batcher) enqueue(entry *LogEntry) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrWriterClosed
+	}
+	select {
+	case b.queue <- entry:
+		return nil
+	default:
+		metricDropped.inc(b.name, "queue_full")
+		return ErrQueueFull
+	}
+}
+
+func (b *batcher) run() {
+	defer func() {
+		batchersMu.Lock()
+		delete(batchers, b)
+		batchersMu.Unlock()
+		close(b.stopped)
+	}()
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	batch := make([]*LogEntry, 0, b.cfg.Size)
+	send := func() {
+		if len(batch) > 0 {
+			b.sendWithRetry(batch)
+			batch = make([]*LogEntry, 0, b.cfg.Size)
+		}
+	}
+	// drain 取出队列中已有的全部日志并发送
+	drain := func() {
+		for {
+			select {
+			case e := <-b.queue:
+				batch = append(batch, e)
+				if len(batch) >= b.cfg.Size {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-b.queue:
+			batch = append(batch, e)
+			if len(batch) >= b.cfg.Size {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ch := <-b.flushCh:
+			drain()
+			close(ch)
+		case <-b.done:
+			drain()
+			return
+		}
+	}
+}
+
+// sendWithRetry 发送一批日志，失败时按RetryConfig重试
+// 关闭超时后不再发送，剩余日志计入shutdown丢弃
+func (b *batcher) sendWithRetry(batch []*LogEntry) {
+	for i := 0; i < b.retry.MaxRetries; i++ {
+		select {
+		case <-b.abort:
+			metricDropped.add(float64(len(batch)), b.name, "shutdown")
+			return
+		default:
+		}
+		if i > 0 {
+			metricRetries.inc(b.name)
+		}
+		start := time.Now()
+		err := b.push(batch)
+		metricPushDuration.observe(time.Since(start).Seconds(), b.name)
+		if err == nil {
+			return
+		}
+		var partial *partialError
+		if errors.As(err, &partial) {
+			batch = partial.failed
+		}
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			metricDropped.add(float64(len(batch)), b.name, "rejected")
+			return
+		}
+		// 如果不是最后一次重试，等待一下再重试
+		if i < b.retry.MaxRetries-1 {
+			timer := time.NewTimer(time.Duration(i+1) * b.retry.Backoff)
+			select {
+			case <-timer.C:
+			case <-b.abort:
+				timer.Stop()
+				metricDropped.add(float64(len(batch)), b.name, "shutdown")
+				return
+			}
+		}
+	}
+	metricDropped.add(float64(len(batch)), b.name, "retries_exhausted")
+}
+
+// flush 发送队列中已有的日志，直到完成或ctx结束
+func (b *batcher) flush(ctx context.Context) error {
+	ch := make(chan struct{})
+	select {
+	case b.flushCh <- ch:
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close 停止接收新日志，发送剩余日志后退出
+// ctx结束时不再等待，并让后台协程放弃重试尽快退出
+func (b *batcher) close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+	b.mu.Unlock()
+	select {
+	case <-b.stopped:
+		return nil
+	case <-ctx.Done():
+		b.abortOnce.Do(func() { close(b.abort) })
+		return ctx.Err()
+	}
+}
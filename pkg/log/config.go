@@ -3,11 +3,57 @@ package log
 // Config 日志配置结构体
 // Level: 日志级别（info/warn/error）
 // FilePath: 本地日志文件路径
+// Files: 额外的本地文件输出，可按级别分流并各自轮转
 // LokiURL: Loki推送地址
+// LokiMetadata: 作为Loki结构化元数据发送的字段，如DefaultLokiMetadataFields，为空时不发送
 // Labels: 日志自定义标签
+// Encoder: 每行JSON的编码配置，零值使用DefaultEncoderConfig
+// AddCaller: 是否记录调用位置
+// Console: 非空时输出到stdout/stderr
+// Syslog: 非空时发送到syslog
+// SLS: 非空时推送到阿里云日志服务
+// OTLP: 非空时通过OTLP/HTTP导出到OpenTelemetry Collector
+// Elastic: 非空时通过_bulk接口写入Elasticsearch/OpenSearch
+// Forward: 非空时以forward协议发送到本地Fluentd/Fluent Bit
+// GELF: 非空时以GELF发送到Graylog
+// Webhook: 非空时将高级别日志作为通知发送到Webhook（Slack、钉钉、飞书等）
+// Kafka: 非空时按配置的编码器写入Kafka主题
+// Writers: 自定义写入器（如RingWriter），键为名称，按名称顺序追加在内置写入器之后
+// Routes: 路由规则，按级别、标签、字段将日志分发到指定写入器，为空时分发到所有写入器
+// Processors: 全局处理器链，在分发前按顺序执行
+// WriterProcessors: 写入器名称到处理器链，只作用于发往该写入器的日志
+// Redact: 非空时在全局处理器之前对Message与Fields脱敏
+// RateLimits: 写入器名称到限流配置，按条数与字节数的令牌桶限流，丢弃时定期输出汇总日志
+// Sampling: 非空时按级别与Message采样，每周期先保留First条，之后每Thereafter条保留一条
+// Dedup: 非空时合并窗口内重复的日志，窗口结束时输出带repeat_count的汇总
+// Metrics: 日志派生指标规则，匹配的日志在进程内计为Prometheus计数器或直方图，随MetricsHandler输出
+// LabelGuard: 非空时在全局处理器之后清洗标签名，并按白名单与集合上限将多余的标签降级为字段
 type Config struct {
-	Level    string            // 日志级别
-	FilePath string            // 本地日志文件路径
-	LokiURL  string            // Loki推送地址
-	Labels   map[string]string // 自定义标签
+	Level            string                     // 日志级别
+	FilePath         string                     // 本地日志文件路径
+	Files            []FileWriterConfig         // 额外的本地文件输出
+	LokiURL          string                     // Loki推送地址
+	LokiMetadata     []string                   // Loki结构化元数据字段
+	Labels           map[string]string          // 自定义标签
+	Encoder          EncoderConfig              // 编码配置
+	AddCaller        bool                       // 记录调用位置
+	Console          *ConsoleWriterConfig       // 控制台输出配置
+	Syslog           *SyslogWriterConfig        // syslog输出配置
+	SLS              *SLSWriterConfig           // 阿里云SLS输出配置
+	OTLP             *OTLPWriterConfig          // OTLP输出配置
+	Elastic          *ElasticWriterConfig       // Elasticsearch输出配置
+	Forward          *ForwardWriterConfig       // Fluent forward输出配置
+	GELF             *GELFWriterConfig          // GELF输出配置
+	Webhook          *WebhookWriterConfig       // 告警Webhook配置
+	Kafka            *KafkaWriterConfig         // Kafka输出配置
+	Writers          map[string]Writer          // 自定义写入器
+	Routes           []RouteRule                // 路由规则
+	Processors       []Processor                // 全局处理器
+	WriterProcessors map[string][]Processor     // 写入器处理器
+	Redact           *RedactConfig              // 脱敏配置
+	RateLimits       map[string]RateLimitConfig // 写入器限流配置
+	Sampling         *SamplerConfig             // 采样配置
+	Dedup            *DedupConfig               // 去重配置
+	LabelGuard       *LabelGuardConfig          // 标签基数保护配置
+	Metrics          []MetricRule               // 日志派生指标规则
 }
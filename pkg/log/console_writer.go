@@ -0,0 +1,195 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 控制台输出格式
+const (
+	ConsoleFormatAuto = "auto" // 终端输出彩色文本，重定向时输出JSON（默认）
+	ConsoleFormatText = "text" // 始终输出对齐的文本
+	ConsoleFormatJSON = "json" // 始终输出JSON
+)
+
+// ConsoleWriterConfig 控制台写入器配置
+// Output: "stdout"（默认）或"stderr"
+// StderrLevel: 不低于该级别的日志改写到stderr，为空时不分流
+// Format: 输出格式，见ConsoleFormat*常量
+// NoColor: 禁用颜色，设置了NO_COLOR环境变量时同样禁用
+type ConsoleWriterConfig struct {
+	Output      string // 默认输出流
+	StderrLevel string // 写到stderr的最低级别
+	Format      string // 输出格式
+	NoColor     bool   // 禁用颜色
+}
+
+// consoleStream 输出流及其是否为终端
+type consoleStream struct {
+	w   io.Writer
+	tty bool
+}
+
+// ConsoleWriter 控制台写入器，实现Writer接口
+// 终端上输出彩色对齐文本，被管道或重定向时输出JSON，便于容器编排系统采集
+
+type ConsoleWriter struct {
+	out         consoleStream
+	errOut      consoleStream
+	stderrLevel string
+	format      string
+	color       bool
+	encoder     *Encoder
+	mu          sync.Mutex
+}
+
+// NewConsoleWriter 创建控制台写入器
+func NewConsoleWriter(c ConsoleWriterConfig) *ConsoleWriter {
+	out := os.Stdout
+	if c.Output == "stderr" {
+		out = os.Stderr
+	}
+	return newConsoleWriter(out, os.Stderr, c)
+}
+
+func newConsoleWriter(out, errOut io.Writer, c ConsoleWriterConfig) *ConsoleWriter {
+	format := c.Format
+	if format == "" {
+		format = ConsoleFormatAuto
+	}
+	_, noColorEnv := os.LookupEnv("NO_COLOR")
+	return &ConsoleWriter{
+		out:         consoleStream{w: out, tty: isTerminal(out)},
+		errOut:      consoleStream{w: errOut, tty: isTerminal(errOut)},
+		stderrLevel: c.StderrLevel,
+		format:      format,
+		color:       !c.NoColor && !noColorEnv,
+	}
+}
+
+// isTerminal 判断输出是否为字符设备（终端）
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// SetEncoder 设置JSON格式输出时的编码器
+func (cw *ConsoleWriter) SetEncoder(enc *Encoder) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.encoder = enc
+}
+
+// Write 实现Writer接口，将日志写到stdout或stderr
+func (cw *ConsoleWriter) Write(entry *LogEntry) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	stream := cw.out
+	if cw.stderrLevel != "" && levelPriority[entry.Level] >= levelPriority[cw.stderrLevel] {
+		stream = cw.errOut
+	}
+
+	var line string
+	switch {
+	case cw.format == ConsoleFormatText || (cw.format == ConsoleFormatAuto && stream.tty):
+		line = formatText(entry, cw.color && stream.tty)
+	default:
+		b, err := encodeEntry(cw.encoder, entry)
+		if err != nil {
+			return fmt.Errorf("failed to format log entry: %w", err)
+		}
+		line = b
+	}
+
+	if _, err := io.WriteString(stream.w, line+"\n"); err != nil {
+		return fmt.Errorf("failed to write to console: %w", err)
+	}
+	return nil
+}
+
+// 各级别的ANSI颜色
+var levelColors = map[string]string{
+	"debug": "\x1b[90m",
+	"info":  "\x1b[36m",
+	"warn":  "\x1b[33m",
+	"error": "\x1b[31m",
+}
+
+const (
+	colorReset = "\x1b[0m"
+	colorDim   = "\x1b[2m"
+)
+
+// consoleMessageWidth 消息列宽，有字段时补齐以对齐字段列
+const consoleMessageWidth = 40
+
+// formatText 格式化为"时间 级别 消息 key=value ..."的对齐文本
+func formatText(entry *LogEntry, color bool) string {
+	var buf bytes.Buffer
+	paint := func(code, s string) {
+		if color && code != "" {
+			buf.WriteString(code + s + colorReset)
+		} else {
+			buf.WriteString(s)
+		}
+	}
+
+	paint(colorDim, time.Unix(entry.Time, 0).Format("2006-01-02 15:04:05"))
+	buf.WriteByte(' ')
+	paint(levelColors[entry.Level], fmt.Sprintf("%-5s", strings.ToUpper(entry.Level)))
+	buf.WriteByte(' ')
+	buf.WriteString(entry.Message)
+
+	if len(entry.Fields) > 0 || entry.Caller != "" {
+		if pad := consoleMessageWidth - displayWidth(entry.Message); pad > 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+	}
+	for _, k := range sortedKeys(entry.Fields) {
+		buf.WriteByte(' ')
+		paint(levelColors[entry.Level], k)
+		buf.WriteString("=" + formatFieldValue(entry.Fields[k]))
+	}
+	if entry.Caller != "" {
+		buf.WriteByte(' ')
+		paint(colorDim, entry.Caller)
+	}
+	return buf.String()
+}
+
+// formatFieldValue 格式化字段值，含空白的字符串加引号
+func formatFieldValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
+
+// displayWidth 估算终端显示宽度，中日韩等宽字符按2列计
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		if r >= 0x1100 && (r <= 0x115f || (r >= 0x2e80 && r <= 0xa4cf) || (r >= 0xac00 && r <= 0xd7a3) ||
+			(r >= 0xf900 && r <= 0xfaff) || (r >= 0xfe30 && r <= 0xfe4f) || (r >= 0xff00 && r <= 0xff60) ||
+			(r >= 0xffe0 && r <= 0xffe6)) {
+			w += 2
+		} else {
+			w++
+		}
+	}
+	return w
+}
@@ -0,0 +1,34 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConsoleWriterSplitAndFormat(t *testing.T) {
+	var out, errOut bytes.Buffer
+	cw := newConsoleWriter(&out, &errOut, ConsoleWriterConfig{StderrLevel: "warn"})
+
+	_ = cw.Write(&LogEntry{Level: "info", Message: "started", Time: 1700000000})
+	_ = cw.Write(&LogEntry{Level: "error", Message: "failed", Fields: map[string]interface{}{"err": "no route"}, Time: 1700000000})
+
+	// 非终端时回退为JSON
+	if !strings.HasPrefix(out.String(), `{"ts":1700000000,"level":"info","msg":"started"}`) {
+		t.Errorf("stdout = %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), `"msg":"failed"`) || strings.Contains(out.String(), "failed") {
+		t.Errorf("error entry should go to stderr only, stderr = %q", errOut.String())
+	}
+
+	out.Reset()
+	text := newConsoleWriter(&out, &out, ConsoleWriterConfig{Format: ConsoleFormatText})
+	_ = text.Write(&LogEntry{Level: "warn", Message: "slow", Fields: map[string]interface{}{"ms": 12, "db": "main pg"}, Time: 1700000000})
+	line := out.String()
+	if !strings.Contains(line, " WARN  slow"+strings.Repeat(" ", consoleMessageWidth-4)+` db="main pg" ms=12`) {
+		t.Errorf("text line = %q", line)
+	}
+	if strings.Contains(line, "\x1b[") {
+		t.Errorf("colour codes written to a non-terminal: %q", line)
+	}
+}
@@ -0,0 +1,275 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ElasticWriterConfig Elasticsearch/OpenSearch写入器配置
+// URL: 集群地址，如"http://opensearch:9200"
+// Index: 索引名模板，{label}替换为标签值，其余部分按Go时间格式以日志时间（UTC）展开，
+// 默认"logs-{service}-2006.01.02"；缺少的标签替换为"unknown"
+// Username/Password: Basic认证；APIKey: 以"ApiKey"方式认证，优先于Basic
+// 批量请求中单条失败时只重试可重试（429/5xx）的条目，已成功的条目不会重复写入
+type ElasticWriterConfig struct {
+	URL      string            // 集群地址
+	Index    string            // 索引名模板
+	Username string            // Basic认证用户名
+	Password string            // Basic认证密码
+	APIKey   string            // API Key（base64编码的id:key）
+	Headers  map[string]string // 附加请求头
+	Labels   map[string]string // 附加标签，与每条日志的Labels合并
+	Batch    BatchConfig       // 批量配置
+	Retry    RetryConfig       // 重试配置
+	Timeout  time.Duration     // 单次请求超时，默认10秒
+}
+
+// ElasticWriter 通过_bulk接口写入Elasticsearch/OpenSearch，实现Writer接口
+// 文档默认使用ECS编码（@timestamp、log.level、message）
+
+type ElasticWriter struct {
+	cfg        ElasticWriterConfig
+	url        string
+	index      []indexPart
+	encoder    *Encoder
+	httpClient *http.Client
+	batcher    *batcher
+}
+
+// indexPart 索引模板的一段：标签占位符或时间格式字面量
+type indexPart struct {
+	label  string
+	layout string
+}
+
+// NewElasticWriter 创建Elasticsearch写入器
+func NewElasticWriter(c ElasticWriterConfig) (*ElasticWriter, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("elasticsearch url is required")
+	}
+	if c.Index == "" {
+		c.Index = "logs-{service}-2006.01.02"
+	}
+	index, err := parseIndexPattern(c.Index)
+	if err != nil {
+		return nil, err
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+
+	ew := &ElasticWriter{
+		cfg:        c,
+		url:        strings.TrimSuffix(c.URL, "/") + "/_bulk",
+		index:      index,
+		encoder:    NewEncoder(ECSEncoderConfig()),
+		httpClient: &http.Client{Timeout: c.Timeout},
+	}
+	ew.batcher = newBatcher("elastic", c.Batch, c.Retry, ew.push)
+	return ew, nil
+}
+
+// parseIndexPattern 将索引模板拆分为标签占位符与时间格式字面量
+func parseIndexPattern(pattern string) ([]indexPart, error) {
+	var parts []indexPart
+	for rest := pattern; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			parts = append(parts, indexPart{layout: rest})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, indexPart{layout: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid index pattern %q: unclosed '{'", pattern)
+		}
+		name := rest[open+1 : open+end]
+		if name == "" {
+			return nil, fmt.Errorf("invalid index pattern %q: empty label", pattern)
+		}
+		parts = append(parts, indexPart{label: name})
+		rest = rest[open+end+1:]
+	}
+	return parts, nil
+}
+
+// SetEncoder 设置文档的编码器
+func (ew *ElasticWriter) SetEncoder(enc *Encoder) {
+	ew.encoder = enc
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台批量写入
+func (ew *ElasticWriter) Write(entry *LogEntry) error {
+	return ew.batcher.enqueue(entry)
+}
+
+// Flush 写入队列中已有的日志
+func (ew *ElasticWriter) Flush(ctx context.Context) error {
+	return ew.batcher.flush(ctx)
+}
+
+// Close 写入剩余日志并停止后台协程
+func (ew *ElasticWriter) Close() error {
+	return ew.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (ew *ElasticWriter) closeContext(ctx context.Context) error {
+	return ew.batcher.close(ctx)
+}
+
+// indexName 根据日志的标签和时间展开索引名
+// 索引名必须为小写，标签值中的非法字符替换为"_"
+func (ew *ElasticWriter) indexName(entry *LogEntry) string {
+	t := time.Unix(entry.Time, 0).UTC()
+	var sb strings.Builder
+	for _, p := range ew.index {
+		if p.label == "" {
+			sb.WriteString(t.Format(p.layout))
+			continue
+		}
+		v, ok := entry.Labels[p.label]
+		if !ok {
+			v, ok = ew.cfg.Labels[p.label]
+		}
+		if !ok || v == "" {
+			v = "unknown"
+		}
+		sb.WriteString(sanitizeIndexValue(v))
+	}
+	return sb.String()
+}
+
+func sanitizeIndexValue(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', '/', '*', '?', '"', '<', '>', '|', ' ', ',', '#', ':':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(v))
+}
+
+// push 编码为NDJSON调用_bulk，只返回需要重试的条目
+func (ew *ElasticWriter) push(batch []*LogEntry) error {
+	var body bytes.Buffer
+	sent := make([]*LogEntry, 0, len(batch))
+	for _, entry := range batch {
+		doc := entry
+		if len(ew.cfg.Labels) > 0 {
+			merged := *entry
+			merged.Labels = map[string]string{}
+			for k, v := range ew.cfg.Labels {
+				merged.Labels[k] = v
+			}
+			for k, v := range entry.Labels {
+				merged.Labels[k] = v
+			}
+			doc = &merged
+		}
+		line, err := encodeEntry(ew.encoder, doc)
+		if err != nil {
+			continue
+		}
+		action, _ := json.Marshal(map[string]map[string]string{"create": {"_index": ew.indexName(doc)}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.WriteString(line)
+		body.WriteByte('\n')
+		sent = append(sent, entry)
+	}
+	if len(sent) == 0 {
+		return nil
+	}
+
+	resp, err := ew.bulk(body.Bytes())
+	if err != nil {
+		return err
+	}
+	if !resp.Errors {
+		return nil
+	}
+	if len(resp.Items) != len(sent) {
+		return permanent(fmt.Errorf("elasticsearch bulk returned %d items for %d documents", len(resp.Items), len(sent)))
+	}
+
+	var failed []*LogEntry
+	var firstErr string
+	rejected := 0
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			if firstErr == "" {
+				firstErr = fmt.Sprintf("status %d: %s", result.Status, bytes.TrimSpace(result.Error))
+			}
+			if retryableStatus(result.Status) {
+				failed = append(failed, sent[i])
+			} else {
+				rejected++
+			}
+		}
+	}
+	err = fmt.Errorf("elasticsearch bulk: %d of %d items failed (%s)", len(failed)+rejected, len(sent), firstErr)
+	if len(failed) == 0 {
+		return permanent(err)
+	}
+	return &partialError{failed: failed, err: err}
+}
+
+// elasticBulkResponse _bulk响应，items与请求中的文档一一对应
+type elasticBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk 发送_bulk请求并解析响应
+func (ew *ElasticWriter) bulk(body []byte) (*elasticBulkResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, ew.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	for k, v := range ew.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if ew.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+ew.cfg.APIKey)
+	} else if ew.cfg.Username != "" {
+		req.SetBasicAuth(ew.cfg.Username, ew.cfg.Password)
+	}
+
+	resp, err := ew.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post to elasticsearch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("elasticsearch returned status code %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		if !retryableStatus(resp.StatusCode) {
+			return nil, permanent(err)
+		}
+		return nil, err
+	}
+	var result elasticBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		// 请求可能已被处理，重试会导致重复写入
+		return nil, permanent(fmt.Errorf("failed to decode elasticsearch bulk response: %w", err))
+	}
+	metricBytesSent.add(float64(len(body)), "elastic")
+	return &result, nil
+}
@@ -0,0 +1,102 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestElasticIndexName(t *testing.T) {
+	ew, err := NewElasticWriter(ElasticWriterConfig{URL: "http://localhost:9200"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ew.Close()
+	ts := time.Date(2024, 3, 5, 23, 0, 0, 0, time.UTC).Unix()
+	if got := ew.indexName(&LogEntry{Labels: map[string]string{"service": "Order API"}, Time: ts}); got != "logs-order_api-2024.03.05" {
+		t.Errorf("index = %q", got)
+	}
+	if got := ew.indexName(&LogEntry{Time: ts}); got != "logs-unknown-2024.03.05" {
+		t.Errorf("index without label = %q", got)
+	}
+	if _, err := NewElasticWriter(ElasticWriterConfig{URL: "http://x", Index: "logs-{service"}); err == nil {
+		t.Error("expected error for unclosed placeholder")
+	}
+}
+
+func TestElasticWriterPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string // 每次请求中的消息
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey secret" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var msgs []string
+		sc := bufio.NewScanner(bytes.NewReader(body))
+		for i := 0; sc.Scan(); i++ {
+			if i%2 == 0 {
+				var action map[string]map[string]string
+				if err := json.Unmarshal(sc.Bytes(), &action); err != nil || action["create"]["_index"] != "logs-api-2023.11.14" {
+					t.Errorf("bad action line %s", sc.Text())
+				}
+				continue
+			}
+			var doc map[string]interface{}
+			_ = json.Unmarshal(sc.Bytes(), &doc)
+			msgs = append(msgs, doc["message"].(string))
+		}
+
+		mu.Lock()
+		requests = append(requests, msgs)
+		first := len(requests) == 1
+		mu.Unlock()
+
+		// 第一次请求：b返回429（可重试），c返回400（不可重试），其余成功
+		var items []string
+		for _, m := range msgs {
+			status := 201
+			if first && m == "b" {
+				status = 429
+			} else if first && m == "c" {
+				status = 400
+			}
+			items = append(items, fmt.Sprintf(`{"create":{"status":%d}}`, status))
+		}
+		fmt.Fprintf(w, `{"errors":%v,"items":[%s]}`, first, strings.Join(items, ","))
+	}))
+	defer srv.Close()
+
+	ew, err := NewElasticWriter(ElasticWriterConfig{
+		URL: srv.URL, APIKey: "secret", Labels: map[string]string{"service": "api"},
+		Retry: RetryConfig{Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{"a", "b", "c", "d"} {
+		_ = ew.Write(&LogEntry{Level: "info", Message: m, Time: 1700000000})
+	}
+	if err := ew.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = ew.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+	if len(requests[0]) != 4 || len(requests[1]) != 1 || requests[1][0] != "b" {
+		t.Errorf("retry should resend only the retryable item, got %v", requests)
+	}
+}
@@ -0,0 +1,308 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 时间格式取值
+const (
+	TimeFormatUnix        = "unix"        // 秒级Unix时间戳（默认）
+	TimeFormatUnixMilli   = "unixmilli"   // 毫秒级Unix时间戳
+	TimeFormatUnixNano    = "unixnano"    // 纳秒级Unix时间戳
+	TimeFormatRFC3339     = "rfc3339"     // RFC3339字符串
+	TimeFormatRFC3339Nano = "rfc3339nano" // 带纳秒的RFC3339字符串
+)
+
+// EncoderConfig 日志编码配置
+// 控制每行JSON的字段名、时间格式以及标签/字段的输出方式
+// 字段名为空时使用默认值；仅当条目带有调用位置（Config.AddCaller）时输出CallerKey
+type EncoderConfig struct {
+	TimeKey       string // 时间字段名，默认"ts"
+	LevelKey      string // 级别字段名，默认"level"
+	MessageKey    string // 消息字段名，默认"msg"
+	CallerKey     string // 调用位置字段名，默认"caller"
+	LabelsKey     string // 标签字段名，默认"labels"
+	FieldsKey     string // 额外字段名，默认"fields"，FlattenFields时忽略
+	TimeFormat    string // 时间格式，见TimeFormat*常量，也可为Go时间布局
+	FlattenFields bool   // 将Fields展开到顶层
+	OmitLabels    bool   // 不在每行中嵌入标签（例如标签已作为Loki流标签）
+}
+
+// DefaultEncoderConfig 默认编码配置
+func DefaultEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		TimeKey:    "ts",
+		LevelKey:   "level",
+		MessageKey: "msg",
+		CallerKey:  "caller",
+		LabelsKey:  "labels",
+		FieldsKey:  "fields",
+		TimeFormat: TimeFormatUnix,
+	}
+}
+
+// ECSEncoderConfig 兼容Elastic Common Schema的编码配置
+func ECSEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		TimeKey:       "@timestamp",
+		LevelKey:      "log.level",
+		MessageKey:    "message",
+		CallerKey:     "log.origin.file.name",
+		LabelsKey:     "labels",
+		TimeFormat:    TimeFormatRFC3339Nano,
+		FlattenFields: true,
+	}
+}
+
+// OTelEncoderConfig 兼容OpenTelemetry日志数据模型的编码配置
+func OTelEncoderConfig() EncoderConfig {
+	return EncoderConfig{
+		TimeKey:    "timestamp",
+		LevelKey:   "severity_text",
+		MessageKey: "body",
+		CallerKey:  "code.filepath",
+		LabelsKey:  "resource",
+		FieldsKey:  "attributes",
+		TimeFormat: TimeFormatUnixNano,
+	}
+}
+
+// withDefaults 补全未设置的字段名
+func (c EncoderConfig) withDefaults() EncoderConfig {
+	d := DefaultEncoderConfig()
+	if c.TimeKey == "" {
+		c.TimeKey = d.TimeKey
+	}
+	if c.LevelKey == "" {
+		c.LevelKey = d.LevelKey
+	}
+	if c.MessageKey == "" {
+		c.MessageKey = d.MessageKey
+	}
+	if c.CallerKey == "" {
+		c.CallerKey = d.CallerKey
+	}
+	if c.LabelsKey == "" {
+		c.LabelsKey = d.LabelsKey
+	}
+	if c.FieldsKey == "" {
+		c.FieldsKey = d.FieldsKey
+	}
+	if c.TimeFormat == "" {
+		c.TimeFormat = d.TimeFormat
+	}
+	return c
+}
+
+// Encoder 按EncoderConfig将LogEntry编码为单行JSON
+type Encoder struct {
+	cfg EncoderConfig
+}
+
+// NewEncoder 创建编码器
+func NewEncoder(c EncoderConfig) *Encoder {
+	return &Encoder{cfg: c.withDefaults()}
+}
+
+var defaultEncoder = NewEncoder(DefaultEncoderConfig())
+
+// Config 返回编码器使用的配置（已补全默认值）
+func (e *Encoder) Config() EncoderConfig {
+	return e.cfg
+}
+
+// Encode 将日志条目编码为JSON（不含换行符）
+// 键的输出顺序固定：时间、级别、消息、调用位置、标签、字段
+func (e *Encoder) Encode(entry *LogEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	add := func(key string, value interface{}) error {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		writeJSONKey(&buf, key)
+		buf.Write(b)
+		return nil
+	}
+
+	if err := add(e.cfg.TimeKey, e.formatTime(entry.Time)); err != nil {
+		return nil, err
+	}
+	if err := add(e.cfg.LevelKey, entry.Level); err != nil {
+		return nil, err
+	}
+	if err := add(e.cfg.MessageKey, entry.Message); err != nil {
+		return nil, err
+	}
+	if entry.Caller != "" {
+		if err := add(e.cfg.CallerKey, entry.Caller); err != nil {
+			return nil, err
+		}
+	}
+	if !e.cfg.OmitLabels && len(entry.Labels) > 0 {
+		if err := add(e.cfg.LabelsKey, entry.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.cfg.FlattenFields {
+		reserved := e.reservedKeys()
+		for _, k := range sortedKeys(entry.Fields) {
+			key := k
+			// 与保留字段冲突时加前缀，避免覆盖
+			if reserved[key] {
+				key = "fields." + key
+			}
+			if err := add(key, entry.Fields[k]); err != nil {
+				return nil, err
+			}
+		}
+	} else if len(entry.Fields) > 0 {
+		if err := add(e.cfg.FieldsKey, entry.Fields); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// formatTime 按配置格式化秒级时间戳
+func (e *Encoder) formatTime(sec int64) interface{} {
+	t := time.Unix(sec, 0)
+	switch e.cfg.TimeFormat {
+	case TimeFormatUnix:
+		return sec
+	case TimeFormatUnixMilli:
+		return t.UnixMilli()
+	case TimeFormatUnixNano:
+		return t.UnixNano()
+	case TimeFormatRFC3339:
+		return t.Format(time.RFC3339)
+	case TimeFormatRFC3339Nano:
+		return t.Format(time.RFC3339Nano)
+	default:
+		return t.Format(e.cfg.TimeFormat)
+	}
+}
+
+// reservedKeys 返回顶层保留的字段名
+func (e *Encoder) reservedKeys() map[string]bool {
+	return map[string]bool{
+		e.cfg.TimeKey:    true,
+		e.cfg.LevelKey:   true,
+		e.cfg.MessageKey: true,
+		e.cfg.CallerKey:  true,
+		e.cfg.LabelsKey:  true,
+	}
+}
+
+func writeJSONKey(buf *bytes.Buffer, key string) {
+	b, _ := json.Marshal(key)
+	buf.Write(b)
+	buf.WriteByte(':')
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Decode 将Encode输出的一行JSON解析回LogEntry
+// FlattenFields时未识别的顶层键归入Fields，"fields."前缀会被还原
+func (e *Encoder) Decode(line []byte) (*LogEntry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	entry := &LogEntry{}
+	if v, ok := raw[e.cfg.TimeKey]; ok {
+		t, err := e.parseTime(v)
+		if err != nil {
+			return nil, err
+		}
+		entry.Time = t
+	}
+	entry.Level, _ = raw[e.cfg.LevelKey].(string)
+	entry.Message, _ = raw[e.cfg.MessageKey].(string)
+	entry.Caller, _ = raw[e.cfg.CallerKey].(string)
+
+	if labels, ok := raw[e.cfg.LabelsKey].(map[string]interface{}); ok {
+		entry.Labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			if s, ok := v.(string); ok {
+				entry.Labels[k] = s
+			}
+		}
+	}
+
+	if !e.cfg.FlattenFields {
+		if fields, ok := raw[e.cfg.FieldsKey].(map[string]interface{}); ok {
+			entry.Fields = fields
+		}
+		return entry, nil
+	}
+
+	reserved := e.reservedKeys()
+	for k, v := range raw {
+		if reserved[k] {
+			continue
+		}
+		if entry.Fields == nil {
+			entry.Fields = map[string]interface{}{}
+		}
+		if orig := strings.TrimPrefix(k, "fields."); orig != k && reserved[orig] {
+			k = orig
+		}
+		entry.Fields[k] = v
+	}
+	return entry, nil
+}
+
+// parseTime 将编码后的时间还原为秒级时间戳
+func (e *Encoder) parseTime(v interface{}) (int64, error) {
+	switch tv := v.(type) {
+	case json.Number:
+		n, err := tv.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q: %w", tv, err)
+		}
+		switch e.cfg.TimeFormat {
+		case TimeFormatUnixMilli:
+			return n / 1e3, nil
+		case TimeFormatUnixNano:
+			return n / 1e9, nil
+		}
+		return n, nil
+	case string:
+		layout := e.cfg.TimeFormat
+		switch layout {
+		case TimeFormatRFC3339, TimeFormatRFC3339Nano, TimeFormatUnix, TimeFormatUnixMilli, TimeFormatUnixNano:
+			layout = time.RFC3339Nano
+		}
+		t, err := time.Parse(layout, tv)
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %q: %w", tv, err)
+		}
+		return t.Unix(), nil
+	}
+	return 0, fmt.Errorf("invalid time value %v", v)
+}
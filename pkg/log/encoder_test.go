@@ -0,0 +1,67 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestEncoderDefaultSchema(t *testing.T) {
+	entry := &LogEntry{
+		Level:   "info",
+		Message: "hello",
+		Labels:  map[string]string{"service": "svc"},
+		Fields:  map[string]interface{}{"user": "alice"},
+		Time:    1700000000,
+	}
+
+	got, err := FormatLogEntry(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ts":1700000000,"level":"info","msg":"hello","labels":{"service":"svc"},"fields":{"user":"alice"}}`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestEncoderFlattenAndOmitLabels(t *testing.T) {
+	enc := NewEncoder(EncoderConfig{
+		TimeKey:       "time",
+		FlattenFields: true,
+		OmitLabels:    true,
+	})
+	entry := &LogEntry{
+		Level:   "warn",
+		Message: "slow",
+		Caller:  "log/log.go:1",
+		Labels:  map[string]string{"service": "svc"},
+		Fields:  map[string]interface{}{"b": 2, "a": 1, "msg": "dup"},
+		Time:    1700000000,
+	}
+
+	b, err := enc.Encode(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":1700000000,"level":"warn","msg":"slow","caller":"log/log.go:1","a":1,"b":2,"fields.msg":"dup"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func TestEncoderECS(t *testing.T) {
+	enc := NewEncoder(ECSEncoderConfig())
+	entry := &LogEntry{Level: "error", Message: "boom", Time: 0}
+
+	b, err := enc.Encode(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"@timestamp":"` + timeRFC3339Nano(0) + `","log.level":"error","message":"boom"}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
+
+func timeRFC3339Nano(sec int64) string {
+	return NewEncoder(EncoderConfig{TimeFormat: TimeFormatRFC3339Nano}).formatTime(sec).(string)
+}
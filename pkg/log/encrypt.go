@@ -0,0 +1,356 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 加密文件格式：
+// 文件头: magic(8) | fileID(16) | keyID长度(1) | keyID
+// 记录帧: 帧长度(4, 大端，不含自身) | 序号(8) | nonce(12) | AES-GCM密文
+// 每帧对应一行日志，附加数据为 fileID|序号，可独立解密且防止跨文件/乱序拼接
+const (
+	encMagic     = "TLOGENC1"
+	encFileIDLen = 16
+	encSeqLen    = 8
+	encMaxFrame  = 64 * 1024 * 1024
+)
+
+// KeyProvider 加密密钥提供者，按密钥ID返回AES密钥（16/24/32字节）
+type KeyProvider interface {
+	Key(keyID string) ([]byte, error)
+}
+
+// KeyProviderFunc 函数形式的KeyProvider
+type KeyProviderFunc func(keyID string) ([]byte, error)
+
+// Key 实现KeyProvider接口
+func (f KeyProviderFunc) Key(keyID string) ([]byte, error) {
+	return f(keyID)
+}
+
+// NewEnvKeyProvider 从环境变量读取密钥（hex或base64编码），忽略密钥ID
+func NewEnvKeyProvider(name string) KeyProvider {
+	return KeyProviderFunc(func(string) ([]byte, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("key env %s not set", name)
+		}
+		return parseKey([]byte(v))
+	})
+}
+
+// NewFileKeyProvider 从文件读取密钥（原始字节、hex或base64编码），忽略密钥ID
+func NewFileKeyProvider(path string) KeyProvider {
+	return KeyProviderFunc(func(string) ([]byte, error) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+		}
+		return parseKey(b)
+	})
+}
+
+// parseKey 解析原始、hex或base64编码的AES密钥
+func parseKey(b []byte) ([]byte, error) {
+	if validKeyLen(len(b)) {
+		return b, nil
+	}
+	s := strings.TrimSpace(string(b))
+	if k, err := hex.DecodeString(s); err == nil && validKeyLen(len(k)) {
+		return k, nil
+	}
+	if k, err := base64.StdEncoding.DecodeString(s); err == nil && validKeyLen(len(k)) {
+		return k, nil
+	}
+	return nil, errors.New("key must be 16, 24 or 32 bytes (raw, hex or base64)")
+}
+
+func validKeyLen(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// EncryptionConfig 本地文件加密配置
+type EncryptionConfig struct {
+	KeyProvider KeyProvider // 密钥提供者
+	KeyID       string      // 密钥ID，写入文件头供解密时选择密钥
+}
+
+// recordCipher 写入端的分帧加密状态
+type recordCipher struct {
+	aead   cipher.AEAD
+	keyID  string
+	fileID [encFileIDLen]byte
+	seq    uint64
+}
+
+func newRecordCipher(c EncryptionConfig) (*recordCipher, error) {
+	if c.KeyProvider == nil {
+		return nil, errors.New("encryption requires a KeyProvider")
+	}
+	if len(c.KeyID) > 255 {
+		return nil, errors.New("key id too long")
+	}
+	key, err := c.KeyProvider.Key(c.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &recordCipher{aead: aead, keyID: c.KeyID}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newFile 为新文件生成fileID并返回文件头
+func (rc *recordCipher) newFile() ([]byte, error) {
+	if _, err := rand.Read(rc.fileID[:]); err != nil {
+		return nil, err
+	}
+	rc.seq = 0
+	hdr := make([]byte, 0, len(encMagic)+encFileIDLen+1+len(rc.keyID))
+	hdr = append(hdr, encMagic...)
+	hdr = append(hdr, rc.fileID[:]...)
+	hdr = append(hdr, byte(len(rc.keyID)))
+	hdr = append(hdr, rc.keyID...)
+	return hdr, nil
+}
+
+// resume 读取已有加密文件，恢复fileID与序号，并返回最后一帧的明文（无帧时为nil）
+// 崩溃留下的不完整末帧被截掉，torn为截掉的字节
+func (rc *recordCipher) resume(path string) (last, torn []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	fr, err := newFrameReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	if fr.keyID != rc.keyID {
+		return nil, nil, fmt.Errorf("file %s is encrypted with key %q, want %q", path, fr.keyID, rc.keyID)
+	}
+	rc.fileID = fr.fileID
+	rc.seq = 0
+
+	for {
+		frame, seq, err := fr.next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errTruncatedFrame) {
+			if torn, err = readTail(f, fr.off); err != nil {
+				return nil, nil, err
+			}
+			if err := os.Truncate(path, fr.off); err != nil {
+				return nil, nil, fmt.Errorf("failed to truncate torn frame of %s: %w", path, err)
+			}
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rc.seq = seq
+		last = frame
+	}
+	if last == nil {
+		return nil, torn, nil
+	}
+	if len(last) < rc.aead.NonceSize() {
+		return nil, nil, fmt.Errorf("last record of %s too short", path)
+	}
+	plain, err := rc.aead.Open(nil, last[:rc.aead.NonceSize()], last[rc.aead.NonceSize():], frameAAD(rc.fileID, rc.seq))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt last record of %s: %w", path, err)
+	}
+	return bytes.TrimRight(plain, "\n"), torn, nil
+}
+
+// readTail 读取文件从off开始的全部内容
+func readTail(f *os.File, off int64) ([]byte, error) {
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// seal 将每一行分别加密为一帧
+func (rc *recordCipher) seal(data []byte) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		line := data
+		if i >= 0 {
+			line, data = data[:i+1], data[i+1:]
+		} else {
+			data = nil
+		}
+
+		rc.seq++
+		nonce := make([]byte, rc.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		ct := rc.aead.Seal(nil, nonce, line, frameAAD(rc.fileID, rc.seq))
+
+		frame := make([]byte, 4+encSeqLen, 4+encSeqLen+len(nonce)+len(ct))
+		binary.BigEndian.PutUint32(frame, uint32(encSeqLen+len(nonce)+len(ct)))
+		binary.BigEndian.PutUint64(frame[4:], rc.seq)
+		frame = append(frame, nonce...)
+		frame = append(frame, ct...)
+		out = append(out, frame...)
+	}
+	return out, nil
+}
+
+func frameAAD(fileID [encFileIDLen]byte, seq uint64) []byte {
+	aad := make([]byte, encFileIDLen+encSeqLen)
+	copy(aad, fileID[:])
+	binary.BigEndian.PutUint64(aad[encFileIDLen:], seq)
+	return aad
+}
+
+// errTruncatedFrame 文件末尾的帧不完整，通常是写入时崩溃
+var errTruncatedFrame = errors.New("truncated frame")
+
+// frameReader 解析加密文件头与记录帧
+type frameReader struct {
+	r      io.Reader
+	fileID [encFileIDLen]byte
+	keyID  string
+	off    int64 // 已读取的完整文件头与帧的字节数
+}
+
+func newFrameReader(r io.Reader) (*frameReader, error) {
+	hdr := make([]byte, len(encMagic)+encFileIDLen+1)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	if string(hdr[:len(encMagic)]) != encMagic {
+		return nil, errors.New("not an encrypted log file")
+	}
+	fr := &frameReader{r: r}
+	copy(fr.fileID[:], hdr[len(encMagic):])
+	keyID := make([]byte, hdr[len(hdr)-1])
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %w", err)
+	}
+	fr.keyID = string(keyID)
+	fr.off = int64(len(hdr) + len(keyID))
+	return fr, nil
+}
+
+// next 返回下一帧的nonce+密文与序号，文件结束时返回io.EOF
+func (fr *frameReader) next() ([]byte, uint64, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(fr.r, lenBuf[:]); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("%w: %v", errTruncatedFrame, err)
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n < encSeqLen || n > encMaxFrame {
+		return nil, 0, fmt.Errorf("invalid frame length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(fr.r, buf); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", errTruncatedFrame, err)
+	}
+	fr.off += 4 + int64(n)
+	return buf[encSeqLen:], binary.BigEndian.Uint64(buf[:encSeqLen]), nil
+}
+
+// IsEncrypted 判断数据是否以加密日志文件头开始
+func IsEncrypted(prefix []byte) bool {
+	return bytes.HasPrefix(prefix, []byte(encMagic))
+}
+
+// decryptReader 将加密文件流还原为明文日志行
+type decryptReader struct {
+	fr   *frameReader
+	aead cipher.AEAD
+	buf  []byte
+	err  error
+}
+
+// NewDecryptReader 返回解密后的明文日志流，密钥按文件头中的密钥ID从kp获取
+func NewDecryptReader(r io.Reader, kp KeyProvider) (io.Reader, error) {
+	fr, err := newFrameReader(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := kp.Key(fr.keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{fr: fr, aead: aead}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		frame, seq, err := d.fr.next()
+		if err != nil {
+			d.err = err
+			continue
+		}
+		ns := d.aead.NonceSize()
+		if len(frame) < ns {
+			d.err = fmt.Errorf("frame %d too short", seq)
+			continue
+		}
+		plain, err := d.aead.Open(nil, frame[:ns], frame[ns:], frameAAD(d.fr.fileID, seq))
+		if err != nil {
+			d.err = fmt.Errorf("failed to decrypt frame %d: %w", seq, err)
+			continue
+		}
+		d.buf = plain
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// DecryptFile 解密加密日志文件（含.gz历史文件），将明文写入w
+func DecryptFile(path string, kp KeyProvider, w io.Writer) error {
+	f, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := NewDecryptReader(bufio.NewReader(f), kp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
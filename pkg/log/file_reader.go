@@ -0,0 +1,40 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// BackupFiles 返回path轮转产生的历史文件（含.gz），按时间从旧到新排序
+func BackupFiles(path string) ([]string, error) {
+	return listBackups(path)
+}
+
+// ParseFileLine 解析FileWriter写入的一行（明文解密后），返回其中的日志条目
+// 兼容"[时间] JSON"前缀格式与审计模式的哈希链记录；
+// 审计模式的创世记录和检查点不含日志条目，此时返回(nil, nil)
+func ParseFileLine(line []byte, enc *Encoder) (*LogEntry, error) {
+	if enc == nil {
+		enc = defaultEncoder
+	}
+	line = bytes.TrimSpace(line)
+
+	// 去掉FileWriter添加的"[2006-01-02 15:04:05] "前缀
+	if len(line) > 0 && line[0] == '[' {
+		if i := bytes.Index(line, []byte("] ")); i >= 0 {
+			line = line[i+2:]
+		}
+	}
+
+	if bytes.HasPrefix(line, []byte(`{"seq":`)) {
+		var l auditLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return nil, err
+		}
+		if len(l.Entry) == 0 {
+			return nil, nil
+		}
+		line = l.Entry
+	}
+	return enc.Decode(line)
+}
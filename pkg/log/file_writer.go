@@ -7,49 +7,252 @@ import (
 	"time"
 )
 
+// FileWriterConfig 本地文件写入器配置
+// Path: 日志文件路径
+// MinLevel: 仅写入不低于该级别的日志，为空时写入全部级别
+// Rotate: 该文件自己的轮转配置
+// Audit: 非空时启用防篡改审计模式，每行为哈希链记录且不带时间戳前缀
+// Encryption: 非空时以AES-GCM分帧加密每一行
+// Perm/Owner: 文件权限与属主，Perm为0时明文文件使用0644、加密文件使用0600
+// Name: 路由规则中引用该文件的名称，默认为Path
+type FileWriterConfig struct {
+	Name       string            // 写入器名称
+	Path       string            // 日志文件路径
+	MinLevel   string            // 最低写入级别
+	Rotate     RotateConfig      // 轮转配置
+	Audit      *AuditConfig      // 审计模式配置
+	Encryption *EncryptionConfig // 加密配置
+	Perm       os.FileMode       // 文件权限
+	Owner      *FileOwner        // 文件属主，为空时不修改
+}
+
+// FileOwner 日志文件属主
+type FileOwner struct {
+	UID int
+	GID int
+}
+
 // FileWriter 本地文件写入器，实现Writer接口
 // 负责将日志写入本地文件
 
 type FileWriter struct {
 	filePath string
 	file     *os.File
+	encoder  *Encoder
+	minLevel string
+	rotate   RotateConfig
+	audit    *auditChain
+	cipher   *recordCipher
+	perm     os.FileMode
+	owner    *FileOwner
+	size     int64     // 当前文件大小
+	openedAt time.Time // 当前文件打开时间
+	bg       sync.WaitGroup
+	bgMu     sync.Mutex
 	mu       sync.Mutex
 }
 
 // NewFileWriter 创建本地文件写入器
 func NewFileWriter(filePath string) (*FileWriter, error) {
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	return NewFileWriterWithConfig(FileWriterConfig{Path: filePath})
+}
+
+// NewFileWriterWithConfig 按配置创建本地文件写入器
+func NewFileWriterWithConfig(c FileWriterConfig) (*FileWriter, error) {
+	if c.MinLevel != "" {
+		if _, ok := levelPriority[c.MinLevel]; !ok {
+			return nil, fmt.Errorf("unknown level %q for file %s", c.MinLevel, c.Path)
+		}
+	}
+	fw := &FileWriter{filePath: c.Path, minLevel: c.MinLevel, rotate: c.Rotate, perm: c.Perm, owner: c.Owner}
+	if c.Audit != nil {
+		fw.audit = newAuditChain(*c.Audit)
+	}
+	if c.Encryption != nil {
+		rc, err := newRecordCipher(*c.Encryption)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init encryption for file %s: %w", c.Path, err)
+		}
+		fw.cipher = rc
+		if fw.perm == 0 {
+			fw.perm = 0600
+		}
+	}
+	if fw.perm == 0 {
+		fw.perm = 0644
+	}
+	if err := fw.open(); err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", c.Path, err)
+	}
+	return fw, nil
+}
+
+// open 打开日志文件并记录当前大小
+func (fw *FileWriter) open() error {
+	file, err := os.OpenFile(fw.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, fw.perm)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+		file.Close()
+		return err
+	}
+	fw.file = file
+	fw.size = info.Size()
+	fw.openedAt = time.Now()
+
+	if err := fw.resume(); err != nil {
+		file.Close()
+		fw.file = nil
+		return err
 	}
-	return &FileWriter{filePath: filePath, file: file}, nil
+	return fw.applyOwnership(fw.filePath)
+}
+
+// resume 恢复加密与审计状态，新文件先写文件头与创世记录
+func (fw *FileWriter) resume() error {
+	var last, torn []byte
+	var err error
+	switch {
+	case fw.cipher != nil && fw.size == 0:
+		hdr, err := fw.cipher.newFile()
+		if err != nil {
+			return err
+		}
+		if err := fw.writeRaw(hdr); err != nil {
+			return err
+		}
+	case fw.cipher != nil:
+		// 崩溃可能留下写了一半的末帧，截掉后从最后一个完整帧接续
+		if last, torn, err = fw.cipher.resume(fw.filePath); err != nil {
+			return err
+		}
+		fw.size -= int64(len(torn))
+	case fw.audit != nil && fw.size > 0:
+		// 崩溃可能留下写了一半的末行，截掉后从最后一个完整行接续
+		if torn, err = truncateTornLine(fw.filePath, fw.size); err != nil {
+			return err
+		}
+		fw.size -= int64(len(torn))
+		if fw.size > 0 {
+			if last, err = readLastLine(fw.filePath); err != nil {
+				return err
+			}
+		}
+	}
+
+	if fw.audit != nil {
+		genesis, err := fw.audit.resume(fw.filePath, last)
+		if err != nil {
+			return err
+		}
+		if genesis != nil {
+			if err := fw.writeData(genesis); err != nil {
+				return err
+			}
+		}
+		if torn != nil {
+			rec, err := fw.audit.recoverTorn(torn)
+			if err != nil {
+				return err
+			}
+			return fw.writeData(rec)
+		}
+	}
+	return nil
+}
+
+// sealAudit 关闭或轮转前为链尾补写检查点
+func (fw *FileWriter) sealAudit() error {
+	if fw.audit == nil {
+		return nil
+	}
+	cp, err := fw.audit.seal()
+	if err != nil || cp == nil {
+		return err
+	}
+	return fw.writeData(cp)
+}
+
+// applyOwnership 按配置设置文件权限与属主
+func (fw *FileWriter) applyOwnership(path string) error {
+	if err := os.Chmod(path, fw.perm); err != nil {
+		return err
+	}
+	if fw.owner != nil {
+		return os.Chown(path, fw.owner.UID, fw.owner.GID)
+	}
+	return nil
+}
+
+// writeData 写入若干完整日志行，加密模式下逐行分帧加密
+func (fw *FileWriter) writeData(b []byte) error {
+	if fw.cipher != nil {
+		sealed, err := fw.cipher.seal(b)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt record: %w", err)
+		}
+		b = sealed
+	}
+	return fw.writeRaw(b)
+}
+
+// writeRaw 写入原始字节并累计文件大小
+func (fw *FileWriter) writeRaw(b []byte) error {
+	n, err := fw.file.Write(b)
+	fw.size += int64(n)
+	return err
+}
+
+// SetEncoder 设置日志行的编码器
+func (fw *FileWriter) SetEncoder(enc *Encoder) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.encoder = enc
 }
 
 // Write 实现Writer接口，将日志写入本地文件
 func (fw *FileWriter) Write(entry *LogEntry) error {
+	if fw.minLevel != "" && levelPriority[entry.Level] < levelPriority[fw.minLevel] {
+		return nil
+	}
+
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
 
 	if fw.file == nil {
-		file, err := os.OpenFile(fw.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
+		if err := fw.open(); err != nil {
 			return fmt.Errorf("failed to reopen file %s: %w", fw.filePath, err)
 		}
-		fw.file = file
 	}
 
 	// 格式化日志条目并添加时间戳
-	line, err := FormatLogEntry(entry)
+	line, err := encodeEntry(fw.encoder, entry)
 	if err != nil {
 		return fmt.Errorf("failed to format log entry: %w", err)
 	}
 
 	// 添加本地时间戳前缀
 	timestamp := time.Unix(entry.Time, 0).Format("2006-01-02 15:04:05")
-	fullLine := fmt.Sprintf("[%s] %s", timestamp, line)
+	fullLine := fmt.Sprintf("[%s] %s\n", timestamp, line)
 
-	_, err = fw.file.WriteString(fullLine + "\n")
-	if err != nil {
+	// 写入前检查是否需要轮转
+	if fw.shouldRotate(int64(len(fullLine))) {
+		if err := fw.rotateFile(); err != nil {
+			return fmt.Errorf("failed to rotate file %s: %w", fw.filePath, err)
+		}
+	}
+
+	data := []byte(fullLine)
+	if fw.audit != nil {
+		data, err = fw.audit.record([]byte(line))
+		if err != nil {
+			return fmt.Errorf("failed to append audit record: %w", err)
+		}
+	}
+
+	if err := fw.writeData(data); err != nil {
 		return fmt.Errorf("failed to write to file: %w", err)
 	}
 
@@ -62,13 +265,17 @@ func (fw *FileWriter) Write(entry *LogEntry) error {
 	return nil
 }
 
-// Close 关闭文件
+// Close 为审计链尾补写检查点后关闭文件，并等待后台压缩与清理完成
 func (fw *FileWriter) Close() error {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
+	defer fw.bg.Wait()
 
 	if fw.file != nil {
-		err := fw.file.Close()
+		err := fw.sealAudit()
+		if cerr := fw.file.Close(); err == nil {
+			err = cerr
+		}
 		fw.file = nil
 		return err
 	}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelSplitFiles(t *testing.T) {
+	dir := t.TempDir()
+	all := filepath.Join(dir, "app.log")
+	errs := filepath.Join(dir, "app.error.log")
+
+	Init(Config{
+		Level: "debug",
+		Files: []FileWriterConfig{
+			{Path: all},
+			{Path: errs, MinLevel: "warn"},
+		},
+	})
+
+	Info("info message")
+	Warn("warn message")
+	Error("error message")
+
+	allData, err := os.ReadFile(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errData, err := os.ReadFile(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(allData), "\n"); n != 3 {
+		t.Errorf("app.log has %d lines, want 3", n)
+	}
+	if strings.Contains(string(errData), "info message") || strings.Count(string(errData), "\n") != 2 {
+		t.Errorf("app.error.log should only contain warn and error, got:\n%s", errData)
+	}
+}
+
+func TestFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rotate.log")
+
+	fw, err := NewFileWriterWithConfig(FileWriterConfig{
+		Path:   path,
+		Rotate: RotateConfig{MaxSize: 200, MaxBackups: 2, Compress: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		entry := &LogEntry{Level: "info", Message: strings.Repeat("x", 50), Time: time.Now().Unix()}
+		if err := fw.Write(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".log.gz") {
+			t.Errorf("backup %s is not compressed", b)
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package log
 
-import (
-	"encoding/json"
-)
-
-// FormatLogEntry 将LogEntry格式化为JSON字符串
+// FormatLogEntry 使用默认编码配置将LogEntry格式化为JSON字符串
 func FormatLogEntry(entry *LogEntry) (string, error) {
-	b, err := json.Marshal(entry)
+	b, err := defaultEncoder.Encode(entry)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// encodeEntry 使用指定编码器编码，未设置时使用默认编码器
+func encodeEntry(enc *Encoder, entry *LogEntry) (string, error) {
+	if enc == nil {
+		enc = defaultEncoder
+	}
+	b, err := enc.Encode(entry)
 	if err != nil {
 		return "", err
 	}
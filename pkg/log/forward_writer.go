@@ -0,0 +1,196 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ForwardWriterConfig Fluentd/Fluent Bit forward协议写入器配置
+// Network: "tcp"（默认）或"unix"
+// Address: 默认"127.0.0.1:24224"
+// TagPrefix: tag前缀，tag为"{TagPrefix}.{service}"，无前缀时为service标签值
+// DefaultTag: 日志没有service标签时使用的tag，默认"app"
+// RequireAck: 为每个chunk请求ack，收到确认前视为失败并重试，实现至少一次投递
+type ForwardWriterConfig struct {
+	Network    string            // 网络类型
+	Address    string            // 地址
+	TagPrefix  string            // tag前缀
+	DefaultTag string            // 默认tag
+	RequireAck bool              // 是否等待ack
+	Labels     map[string]string // 附加标签，与每条日志的Labels合并
+	Batch      BatchConfig       // 批量配置
+	Retry      RetryConfig       // 重试配置
+	Timeout    time.Duration     // 连接、写入与等待ack的超时，默认5秒
+}
+
+// ForwardWriter 以forward协议PackedForward模式发送日志，实现Writer接口
+// 每批日志按tag分组，每组编码为一条[tag, entries, option]消息
+
+type ForwardWriter struct {
+	cfg     ForwardWriterConfig
+	conn    *reconnectConn
+	batcher *batcher
+}
+
+// NewForwardWriter 创建forward写入器，连接在首次发送时建立
+func NewForwardWriter(c ForwardWriterConfig) (*ForwardWriter, error) {
+	if c.Network == "" {
+		c.Network = "tcp"
+	}
+	if c.Network != "tcp" && c.Network != "unix" {
+		return nil, fmt.Errorf("unsupported forward network %q", c.Network)
+	}
+	if c.Address == "" {
+		c.Address = "127.0.0.1:24224"
+	}
+	if c.DefaultTag == "" {
+		c.DefaultTag = "app"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	fw := &ForwardWriter{cfg: c, conn: newReconnectConn(c.Address, c.Timeout, c.Network)}
+	fw.batcher = newBatcher("forward", c.Batch, c.Retry, fw.push)
+	return fw, nil
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台批量发送
+func (fw *ForwardWriter) Write(entry *LogEntry) error {
+	return fw.batcher.enqueue(entry)
+}
+
+// Flush 发送队列中已有的日志
+func (fw *ForwardWriter) Flush(ctx context.Context) error {
+	return fw.batcher.flush(ctx)
+}
+
+// Close 发送剩余日志，停止后台协程并关闭连接
+func (fw *ForwardWriter) Close() error {
+	return fw.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (fw *ForwardWriter) closeContext(ctx context.Context) error {
+	err := fw.batcher.close(ctx)
+	if cerr := fw.conn.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// tag 由service标签得到tag
+func (fw *ForwardWriter) tag(labels map[string]string) string {
+	svc := labels["service"]
+	switch {
+	case svc == "":
+		return fw.cfg.DefaultTag
+	case fw.cfg.TagPrefix != "":
+		return fw.cfg.TagPrefix + "." + svc
+	}
+	return svc
+}
+
+// push 按tag分组，每组一条PackedForward消息
+// 某组失败时返回partialError，重试只重发该组及之后尚未发送的组
+func (fw *ForwardWriter) push(batch []*LogEntry) error {
+	groups := map[string][]byte{}
+	entries := map[string][]*LogEntry{}
+	var order []string
+	for _, entry := range batch {
+		labels := map[string]string{}
+		for k, v := range fw.cfg.Labels {
+			labels[k] = v
+		}
+		for k, v := range entry.Labels {
+			labels[k] = v
+		}
+		tag := fw.tag(labels)
+		if _, ok := groups[tag]; !ok {
+			order = append(order, tag)
+		}
+		groups[tag] = appendForwardEntry(groups[tag], entry, labels)
+		entries[tag] = append(entries[tag], entry)
+	}
+
+	for i, tag := range order {
+		if err := fw.send(tag, groups[tag], len(entries[tag])); err != nil {
+			var failed []*LogEntry
+			for _, t := range order[i:] {
+				failed = append(failed, entries[t]...)
+			}
+			return &partialError{failed: failed, err: err}
+		}
+	}
+	return nil
+}
+
+// appendForwardEntry 编码一条[time, record]，record包含level、message、caller、标签和字段
+// 字段与前面的键冲突时加"fields."前缀
+func appendForwardEntry(b []byte, entry *LogEntry, labels map[string]string) []byte {
+	record := map[string]interface{}{
+		"level":   entry.Level,
+		"message": entry.Message,
+	}
+	if entry.Caller != "" {
+		record["caller"] = entry.Caller
+	}
+	for k, v := range labels {
+		if _, exists := record[k]; !exists {
+			record[k] = v
+		}
+	}
+	for k, v := range entry.Fields {
+		if _, exists := record[k]; exists {
+			k = "fields." + k
+		}
+		record[k] = v
+	}
+	b = appendMsgpackArrayHeader(b, 2)
+	b = appendMsgpackInt(b, entry.Time)
+	return appendMsgpack(b, record)
+}
+
+// send 发送一条PackedForward消息，RequireAck时等待服务端返回相同的chunk
+func (fw *ForwardWriter) send(tag string, entries []byte, count int) error {
+	option := map[string]interface{}{"size": count}
+	var chunk string
+	if fw.cfg.RequireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return fmt.Errorf("failed to generate chunk id: %w", err)
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+		option["chunk"] = chunk
+	}
+
+	msg := appendMsgpackArrayHeader(nil, 3)
+	msg = appendMsgpackString(msg, tag)
+	msg = appendMsgpackBin(msg, entries)
+	msg = appendMsgpack(msg, option)
+
+	err := fw.conn.do(func(conn net.Conn) error {
+		if _, err := conn.Write(msg); err != nil {
+			return fmt.Errorf("failed to send forward message: %w", err)
+		}
+		if chunk == "" {
+			return nil
+		}
+		resp, err := readMsgpack(bufio.NewReader(conn))
+		if err != nil {
+			return fmt.Errorf("failed to read forward ack: %w", err)
+		}
+		if m, ok := resp.(map[string]interface{}); !ok || m["ack"] != chunk {
+			return fmt.Errorf("unexpected forward ack %v", resp)
+		}
+		return nil
+	})
+	if err == nil {
+		metricBytesSent.add(float64(len(msg)), "forward")
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	in := map[string]interface{}{
+		"s":     "hello",
+		"long":  string(bytes.Repeat([]byte("x"), 300)),
+		"n":     -5,
+		"big":   int64(-1 << 40),
+		"u":     uint64(1 << 40),
+		"f":     1.5,
+		"b":     true,
+		"nil":   nil,
+		"list":  []interface{}{"a", 1},
+		"dur":   time.Second,
+		"point": struct{ X int }{X: 3},
+	}
+	out, err := readMsgpack(bufio.NewReader(bytes.NewReader(appendMsgpack(nil, in))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := out.(map[string]interface{})
+	if m["s"] != "hello" || len(m["long"].(string)) != 300 || m["n"] != int64(-5) || m["big"] != int64(-1<<40) {
+		t.Errorf("unexpected decode %v", m)
+	}
+	if m["u"] != int64(1<<40) || m["f"] != 1.5 || m["b"] != true || m["nil"] != nil || m["dur"] != "1s" {
+		t.Errorf("unexpected decode %v", m)
+	}
+	if p := m["point"].(map[string]interface{}); p["X"] != int64(3) {
+		t.Errorf("struct decoded as %v", m["point"])
+	}
+}
+
+func TestForwardWriterPackedForwardWithAck(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan []interface{}, 4)
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			v, err := readMsgpack(r)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			msg := v.([]interface{})
+			msgs <- msg
+			if i == 0 {
+				// 第一次不回ack直接断开，验证重发
+				conn.Close()
+				continue
+			}
+			chunk := msg[2].(map[string]interface{})["chunk"]
+			_, _ = conn.Write(appendMsgpack(nil, map[string]interface{}{"ack": chunk}))
+			conn.Close()
+		}
+	}()
+
+	fw, err := NewForwardWriter(ForwardWriterConfig{
+		Address: ln.Addr().String(), TagPrefix: "k8s", RequireAck: true,
+		Timeout: time.Second, Retry: RetryConfig{Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"service": "api"}
+	_ = fw.Write(&LogEntry{Level: "info", Message: "a", Labels: labels, Fields: map[string]interface{}{"level": "x", "n": 1}, Time: 1700000000})
+	_ = fw.Write(&LogEntry{Level: "warn", Message: "b", Labels: labels, Time: 1700000001})
+	if err := fw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = fw.Close()
+
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2 (original and resend)", len(msgs))
+	}
+	<-msgs
+	msg := <-msgs
+	if msg[0] != "k8s.api" {
+		t.Errorf("tag = %v", msg[0])
+	}
+	option := msg[2].(map[string]interface{})
+	if option["size"] != int64(2) || option["chunk"] == "" {
+		t.Errorf("option = %v", option)
+	}
+	r := bufio.NewReader(bytes.NewReader(msg[1].([]byte)))
+	first, err := readMsgpack(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := first.([]interface{})
+	rec := ev[1].(map[string]interface{})
+	if ev[0] != int64(1700000000) || rec["message"] != "a" || rec["level"] != "info" || rec["fields.level"] != "x" || rec["n"] != int64(1) || rec["service"] != "api" {
+		t.Errorf("unexpected event %v", ev)
+	}
+	if _, err := readMsgpack(r); err != nil {
+		t.Errorf("second event: %v", err)
+	}
+}
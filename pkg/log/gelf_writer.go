@@ -0,0 +1,250 @@
+package log
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// GELF压缩方式
+const (
+	GELFCompressGzip = "gzip"
+	GELFCompressZlib = "zlib"
+	GELFCompressNone = "none"
+)
+
+const (
+	gelfChunkHeader = 12  // 0x1e 0x0f + 8字节消息ID + 序号 + 总数
+	gelfMaxChunks   = 128 // GELF规定单条消息最多128块
+)
+
+// GELFWriterConfig Graylog GELF写入器配置
+// Network: "udp"（默认）或"tcp"；TCP以空字节分隔消息且不支持压缩
+// Address: 默认"127.0.0.1:12201"
+// Compression: UDP消息的压缩方式，"gzip"（默认）、"zlib"或"none"
+// ChunkSize: UDP单个数据报的最大字节数，超过时分块发送，默认1420
+// Host: GELF的host字段，默认主机名
+type GELFWriterConfig struct {
+	Network     string        // 网络类型
+	Address     string        // 地址
+	Compression string        // 压缩方式
+	ChunkSize   int           // UDP分块大小
+	Host        string        // 主机名
+	Batch       BatchConfig   // 批量配置
+	Retry       RetryConfig   // 重试配置
+	Timeout     time.Duration // 连接与写超时
+}
+
+// GELFWriter GELF 1.1写入器，实现Writer接口
+// Labels与Fields作为"_"前缀的附加字段，级别映射为syslog严重性；日志进入队列后由后台协程发送
+
+type GELFWriter struct {
+	cfg     GELFWriterConfig
+	conn    *reconnectConn
+	batcher *batcher
+}
+
+// NewGELFWriter 创建GELF写入器，连接在首次写入时建立
+func NewGELFWriter(c GELFWriterConfig) (*GELFWriter, error) {
+	if c.Network == "" {
+		c.Network = "udp"
+	}
+	if c.Network != "udp" && c.Network != "tcp" {
+		return nil, fmt.Errorf("unsupported gelf network %q", c.Network)
+	}
+	if c.Address == "" {
+		c.Address = "127.0.0.1:12201"
+	}
+	if c.Compression == "" {
+		c.Compression = GELFCompressGzip
+	}
+	switch c.Compression {
+	case GELFCompressGzip, GELFCompressZlib, GELFCompressNone:
+	default:
+		return nil, fmt.Errorf("unknown gelf compression %q", c.Compression)
+	}
+	if c.ChunkSize <= gelfChunkHeader {
+		c.ChunkSize = 1420
+	}
+	if c.Host == "" {
+		c.Host, _ = os.Hostname()
+	}
+	gw := &GELFWriter{cfg: c, conn: newReconnectConn(c.Address, c.Timeout, c.Network)}
+	gw.batcher = newBatcher("gelf", c.Batch, c.Retry, gw.push)
+	return gw, nil
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台发送到Graylog
+func (gw *GELFWriter) Write(entry *LogEntry) error {
+	return gw.batcher.enqueue(entry)
+}
+
+// Flush 发送队列中已有的日志
+func (gw *GELFWriter) Flush(ctx context.Context) error {
+	return gw.batcher.flush(ctx)
+}
+
+// Close 发送剩余日志，停止后台协程并关闭连接
+func (gw *GELFWriter) Close() error {
+	return gw.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (gw *GELFWriter) closeContext(ctx context.Context) error {
+	err := gw.batcher.close(ctx)
+	if cerr := gw.conn.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// push 逐条发送，失败时返回partialError，重试从失败的日志开始
+// 无法编码或超过分块上限的日志重试也无法发送，直接丢弃
+func (gw *GELFWriter) push(batch []*LogEntry) error {
+	for i, entry := range batch {
+		msg, err := json.Marshal(gw.message(entry))
+		if err != nil {
+			metricDropped.inc("gelf", "rejected")
+			continue
+		}
+
+		var size int
+		if gw.cfg.Network == "tcp" {
+			size, err = len(msg)+1, gw.conn.write(append(msg, 0))
+		} else {
+			size, err = gw.writeUDP(msg)
+		}
+		var pe *permanentError
+		if errors.As(err, &pe) {
+			metricDropped.inc("gelf", "rejected")
+			continue
+		}
+		if err != nil {
+			return &partialError{failed: batch[i:], err: fmt.Errorf("failed to write to graylog: %w", err)}
+		}
+		metricBytesSent.add(float64(size), "gelf")
+	}
+	return nil
+}
+
+// message 将日志映射为GELF消息
+// 多行消息的第一行作为short_message，完整内容作为full_message
+func (gw *GELFWriter) message(entry *LogEntry) map[string]interface{} {
+	sev, ok := syslogSeverities[entry.Level]
+	if !ok {
+		sev = syslogSeverities["info"]
+	}
+	short := entry.Message
+	if short == "" {
+		short = "-" // short_message不能为空
+	}
+	msg := map[string]interface{}{
+		"version":   "1.1",
+		"host":      gw.cfg.Host,
+		"timestamp": entry.Time,
+		"level":     sev,
+	}
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		msg["full_message"] = entry.Message
+		short = short[:i]
+	}
+	msg["short_message"] = short
+
+	if entry.Caller != "" {
+		msg["_caller"] = entry.Caller
+	}
+	for k, v := range entry.Labels {
+		msg[gelfFieldName(k)] = v
+	}
+	for k, v := range entry.Fields {
+		msg[gelfFieldName(k)] = gelfFieldValue(v)
+	}
+	return msg
+}
+
+// gelfFieldName 附加字段名须匹配^[\w\.\-]*$，"_id"为保留字段
+func gelfFieldName(k string) string {
+	k = strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, k)
+	if k == "id" {
+		k = "id_"
+	}
+	return "_" + k
+}
+
+// gelfFieldValue 附加字段只能是字符串或数字
+func gelfFieldValue(v interface{}) interface{} {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	}
+	return fieldString(v)
+}
+
+// writeUDP 压缩后发送，超过ChunkSize时分块，返回压缩后的字节数
+func (gw *GELFWriter) writeUDP(msg []byte) (int, error) {
+	payload, err := gw.compress(msg)
+	if err != nil {
+		return 0, permanent(err)
+	}
+	if len(payload) <= gw.cfg.ChunkSize {
+		return len(payload), gw.conn.write(payload)
+	}
+
+	size := gw.cfg.ChunkSize - gelfChunkHeader
+	count := (len(payload) + size - 1) / size
+	if count > gelfMaxChunks {
+		return 0, permanent(fmt.Errorf("gelf message too large: %d bytes needs %d chunks", len(payload), count))
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return 0, fmt.Errorf("failed to generate gelf message id: %w", err)
+	}
+	return len(payload), gw.conn.do(func(conn net.Conn) error {
+		for i := 0; i < count; i++ {
+			chunk := make([]byte, 0, gw.cfg.ChunkSize)
+			chunk = append(chunk, 0x1e, 0x0f)
+			chunk = append(chunk, id...)
+			chunk = append(chunk, byte(i), byte(count))
+			chunk = append(chunk, payload[i*size:min((i+1)*size, len(payload))]...)
+			if _, err := conn.Write(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (gw *GELFWriter) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch gw.cfg.Compression {
+	case GELFCompressGzip:
+		zw := gzip.NewWriter(&buf)
+		zw.Write(msg)
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress gelf message: %w", err)
+		}
+	case GELFCompressZlib:
+		zw := zlib.NewWriter(&buf)
+		zw.Write(msg)
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress gelf message: %w", err)
+		}
+	default:
+		return msg, nil
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGELFWriterChunkedUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	gw, err := NewGELFWriter(GELFWriterConfig{
+		Address: pc.LocalAddr().String(), Compression: GELFCompressZlib, ChunkSize: 200, Host: "web1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gw.Close()
+
+	// 随机内容难以压缩，保证需要分块
+	noise := make([]byte, 1500)
+	rand.New(rand.NewSource(1)).Read(noise)
+	err = gw.Write(&LogEntry{
+		Level: "error", Message: "boom\nstack trace", Labels: map[string]string{"service": "api"},
+		Fields: map[string]interface{}{"noise": hex.EncodeToString(noise), "id": 7, "ok": true}, Time: 1700000000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := map[byte][]byte{}
+	var total byte
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for total == 0 || len(chunks) < int(total) {
+		buf := make([]byte, 512)
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read chunk: %v", err)
+		}
+		if n > 200 || buf[0] != 0x1e || buf[1] != 0x0f {
+			t.Fatalf("bad chunk header % x (len %d)", buf[:2], n)
+		}
+		total = buf[11]
+		chunks[buf[10]] = buf[12:n]
+	}
+	var payload []byte
+	for i := byte(0); i < total; i++ {
+		payload = append(payload, chunks[i]...)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(zr)
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["version"] != "1.1" || msg["host"] != "web1" || msg["level"] != float64(3) || msg["timestamp"] != float64(1700000000) {
+		t.Errorf("unexpected header fields %v", msg)
+	}
+	if msg["short_message"] != "boom" || msg["full_message"] != "boom\nstack trace" {
+		t.Errorf("unexpected messages %v %v", msg["short_message"], msg["full_message"])
+	}
+	if msg["_service"] != "api" || msg["_id_"] != float64(7) || msg["_ok"] != "true" || len(msg["_noise"].(string)) != 3000 {
+		t.Errorf("unexpected additional fields %v", msg)
+	}
+}
+
+func TestGELFWriterTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for len(msgs) < 2 {
+			m, err := r.ReadString(0)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, strings.TrimSuffix(m, "\x00"))
+		}
+		got <- msgs
+	}()
+
+	gw, err := NewGELFWriter(GELFWriterConfig{Network: "tcp", Address: ln.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gw.Close()
+	_ = gw.Write(&LogEntry{Level: "info", Message: "one", Time: 1})
+	_ = gw.Write(&LogEntry{Level: "warn", Message: "two", Time: 2})
+	if err := gw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := <-got
+	if len(msgs) != 2 || !strings.Contains(msgs[0], `"short_message":"one"`) || !strings.Contains(msgs[1], `"level":4`) {
+		t.Errorf("unexpected tcp messages %q", msgs)
+	}
+}
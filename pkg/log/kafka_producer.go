@@ -0,0 +1,550 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 最小化的Kafka生产者：Metadata v1查找分区leader，Produce v3发送RecordBatch v2
+// 不支持SASL/TLS与幂等生产，需要这些能力时通过KafkaWriterConfig.Producer注入其他实现
+
+// Kafka API
+const (
+	kafkaAPIProduce  = 0
+	kafkaAPIMetadata = 3
+)
+
+// Kafka压缩方式
+const (
+	KafkaCompressNone = "none"
+	KafkaCompressGzip = "gzip"
+)
+
+// kafkaRetriableErrors 可重试的错误码
+var kafkaRetriableErrors = map[int16]bool{
+	2:  true, // CORRUPT_MESSAGE
+	3:  true, // UNKNOWN_TOPIC_OR_PARTITION
+	5:  true, // LEADER_NOT_AVAILABLE
+	6:  true, // NOT_LEADER_OR_FOLLOWER
+	7:  true, // REQUEST_TIMED_OUT
+	8:  true, // BROKER_NOT_AVAILABLE
+	9:  true, // REPLICA_NOT_AVAILABLE
+	13: true, // NETWORK_EXCEPTION
+	14: true, // COORDINATOR_LOAD_IN_PROGRESS
+	15: true, // COORDINATOR_NOT_AVAILABLE
+	19: true, // NOT_ENOUGH_REPLICAS
+	20: true, // NOT_ENOUGH_REPLICAS_AFTER_APPEND
+}
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaPartialError 部分分区写入失败，failed为需要重试的消息下标
+type kafkaPartialError struct {
+	failed []int
+	err    error
+}
+
+func (e *kafkaPartialError) Error() string { return e.err.Error() }
+func (e *kafkaPartialError) Unwrap() error { return e.err }
+
+// kafkaProducer 内置的Kafka生产者
+type kafkaProducer struct {
+	bootstrap   []string
+	clientID    string
+	compression string
+	acks        int16
+	timeout     time.Duration
+
+	mu      sync.Mutex
+	brokers map[int32]string           // broker id到地址
+	conns   map[string]*kafkaConn      // 地址到连接
+	leaders map[string]map[int32]int32 // topic到分区leader
+	parts   map[string][]int32         // topic中有leader的分区列表
+	counts  map[string]int32           // topic的分区总数，含暂无leader的分区
+	next    map[string]int             // 无key消息轮询的分区下标
+}
+
+func newKafkaProducer(brokers []string, clientID, compression string, acks int16, timeout time.Duration) *kafkaProducer {
+	return &kafkaProducer{
+		bootstrap:   brokers,
+		clientID:    clientID,
+		compression: compression,
+		acks:        acks,
+		timeout:     timeout,
+		brokers:     map[int32]string{},
+		conns:       map[string]*kafkaConn{},
+		leaders:     map[string]map[int32]int32{},
+		parts:       map[string][]int32{},
+		counts:      map[string]int32{},
+		next:        map[string]int{},
+	}
+}
+
+// Produce 实现KafkaProducer接口
+// 有key的消息与Java客户端的默认分区器一致，按murmur2哈希对分区总数取模得到分区号，
+// 该分区暂无leader时消息留待重试，不改投其他分区，以保持同一key的顺序；
+// 无key的消息每批轮换一个有leader的分区
+func (p *kafkaProducer) Produce(ctx context.Context, topic string, msgs []KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	parts, err := p.partitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+	sticky := parts[p.next[topic]%len(parts)]
+	p.next[topic]++
+
+	var failed []int
+	var firstErr error
+	permanentFailure := false
+
+	// 按leader分组：leader -> 分区 -> 消息下标
+	byLeader := map[int32]map[int32][]int{}
+	for i, m := range msgs {
+		part := sticky
+		if m.Key != nil {
+			part = int32(int(kafkaMurmur2(m.Key)&0x7fffffff) % int(p.counts[topic]))
+		}
+		leader, ok := p.leaders[topic][part]
+		if !ok {
+			failed = append(failed, i)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("kafka partition %s/%d has no leader", topic, part)
+			}
+			continue
+		}
+		if byLeader[leader] == nil {
+			byLeader[leader] = map[int32][]int{}
+		}
+		byLeader[leader][part] = append(byLeader[leader][part], i)
+	}
+
+	for leader, partMsgs := range byLeader {
+		errs, err := p.produceTo(ctx, leader, topic, msgs, partMsgs)
+		if err != nil {
+			// 整个请求失败，该broker上的所有消息都需要重试
+			for _, idx := range partMsgs {
+				failed = append(failed, idx...)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for part, code := range errs {
+			if code == 0 {
+				continue
+			}
+			err := fmt.Errorf("kafka produce to %s/%d failed with error code %d", topic, part, code)
+			if firstErr == nil {
+				firstErr = err
+			}
+			if kafkaRetriableErrors[code] {
+				failed = append(failed, partMsgs[part]...)
+			} else {
+				permanentFailure = true
+			}
+		}
+	}
+	if firstErr == nil {
+		return nil
+	}
+	// leader可能已变化，下次发送前重新获取元数据
+	delete(p.leaders, topic)
+	delete(p.parts, topic)
+	if len(failed) == 0 && permanentFailure {
+		return permanent(firstErr)
+	}
+	return &kafkaPartialError{failed: failed, err: firstErr}
+}
+
+// Close 关闭所有连接
+func (p *kafkaProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, c := range p.conns {
+		c.conn.Close()
+		delete(p.conns, addr)
+	}
+	return nil
+}
+
+// partitions 返回topic的分区列表，必要时获取元数据
+func (p *kafkaProducer) partitions(ctx context.Context, topic string) ([]int32, error) {
+	if parts := p.parts[topic]; len(parts) > 0 {
+		return parts, nil
+	}
+	addrs := append([]string{}, p.bootstrap...)
+	for _, addr := range p.brokers {
+		addrs = append(addrs, addr)
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		if err := p.fetchMetadata(ctx, addr, topic); err != nil {
+			lastErr = err
+			continue
+		}
+		if parts := p.parts[topic]; len(parts) > 0 {
+			return parts, nil
+		}
+		lastErr = fmt.Errorf("kafka topic %q has no available partitions", topic)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no kafka brokers configured")
+	}
+	return nil, lastErr
+}
+
+// fetchMetadata 发送Metadata v1请求并更新broker与分区leader
+func (p *kafkaProducer) fetchMetadata(ctx context.Context, addr, topic string) error {
+	req := appendKafkaInt32(nil, 1)
+	req = appendKafkaString(req, topic)
+	resp, err := p.roundTrip(ctx, addr, kafkaAPIMetadata, 1, req)
+	if err != nil {
+		return err
+	}
+
+	d := &kafkaDecoder{b: resp}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		p.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+	leaders := map[int32]int32{}
+	var parts []int32
+	var count int32
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			pcode := d.int16()
+			index := d.int32()
+			leader := d.int32()
+			d.int32Array() // replicas
+			d.int32Array() // isr
+			if name == topic {
+				count++
+			}
+			if name == topic && code == 0 && pcode == 0 && leader >= 0 {
+				leaders[index] = leader
+				parts = append(parts, index)
+			}
+		}
+		if name == topic && code != 0 {
+			return fmt.Errorf("kafka metadata for topic %q failed with error code %d", topic, code)
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("failed to decode kafka metadata: %w", d.err)
+	}
+	p.leaders[topic] = leaders
+	p.parts[topic] = parts
+	p.counts[topic] = count
+	return nil
+}
+
+// produceTo 向一个broker发送Produce v3请求，返回各分区的错误码
+func (p *kafkaProducer) produceTo(ctx context.Context, leader int32, topic string, msgs []KafkaMessage, partMsgs map[int32][]int) (map[int32]int16, error) {
+	addr, ok := p.brokers[leader]
+	if !ok {
+		return nil, fmt.Errorf("unknown kafka broker id %d", leader)
+	}
+
+	req := appendKafkaInt16(nil, -1) // transactional_id
+	req = appendKafkaInt16(req, p.acks)
+	req = appendKafkaInt32(req, int32(p.timeout/time.Millisecond))
+	req = appendKafkaInt32(req, 1)
+	req = appendKafkaString(req, topic)
+	req = appendKafkaInt32(req, int32(len(partMsgs)))
+	for part, idx := range partMsgs {
+		batch := make([]KafkaMessage, len(idx))
+		for i, j := range idx {
+			batch[i] = msgs[j]
+		}
+		records, err := encodeKafkaRecordBatch(batch, p.compression)
+		if err != nil {
+			return nil, permanent(err)
+		}
+		req = appendKafkaInt32(req, part)
+		req = appendKafkaInt32(req, int32(len(records)))
+		req = append(req, records...)
+	}
+
+	resp, err := p.roundTrip(ctx, addr, kafkaAPIProduce, 3, req)
+	if err != nil {
+		return nil, err
+	}
+	d := &kafkaDecoder{b: resp}
+	errs := map[int32]int16{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string()
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			index := d.int32()
+			errs[index] = d.int16()
+			d.int64() // base_offset
+			d.int64() // log_append_time
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode kafka produce response: %w", d.err)
+	}
+	return errs, nil
+}
+
+// kafkaConn 到一个broker的连接
+type kafkaConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	corr int32
+}
+
+// roundTrip 发送请求并读取响应体，失败时关闭连接以便下次重建
+func (p *kafkaProducer) roundTrip(ctx context.Context, addr string, apiKey, version int16, body []byte) ([]byte, error) {
+	c, ok := p.conns[addr]
+	if !ok {
+		d := net.Dialer{Timeout: p.timeout}
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to kafka broker %s: %w", addr, err)
+		}
+		c = &kafkaConn{conn: conn, r: bufio.NewReader(conn)}
+		p.conns[addr] = c
+	}
+	resp, err := c.roundTrip(p.clientID, apiKey, version, body, p.timeout)
+	if err != nil {
+		c.conn.Close()
+		delete(p.conns, addr)
+		return nil, fmt.Errorf("kafka request to %s failed: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (c *kafkaConn) roundTrip(clientID string, apiKey, version int16, body []byte, timeout time.Duration) ([]byte, error) {
+	c.corr++
+	req := appendKafkaInt32(nil, 0) // 长度占位
+	req = appendKafkaInt16(req, apiKey)
+	req = appendKafkaInt16(req, version)
+	req = appendKafkaInt32(req, c.corr)
+	req = appendKafkaString(req, clientID)
+	req = append(req, body...)
+	binary.BigEndian.PutUint32(req, uint32(len(req)-4))
+
+	_ = c.conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+	var head [8]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.BigEndian.Uint32(head[:4]))
+	if corr := int32(binary.BigEndian.Uint32(head[4:])); corr != c.corr {
+		return nil, fmt.Errorf("correlation id mismatch: got %d, want %d", corr, c.corr)
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// encodeKafkaRecordBatch 编码RecordBatch v2（magic=2）
+func encodeKafkaRecordBatch(msgs []KafkaMessage, compression string) ([]byte, error) {
+	base := msgs[0].Time.UnixMilli()
+	maxTS := base
+	var records []byte
+	for i, m := range msgs {
+		ts := m.Time.UnixMilli()
+		maxTS = max(maxTS, ts)
+		var rec []byte
+		rec = append(rec, 0) // attributes
+		rec = binary.AppendVarint(rec, ts-base)
+		rec = binary.AppendVarint(rec, int64(i))
+		if m.Key == nil {
+			rec = binary.AppendVarint(rec, -1)
+		} else {
+			rec = binary.AppendVarint(rec, int64(len(m.Key)))
+			rec = append(rec, m.Key...)
+		}
+		rec = binary.AppendVarint(rec, int64(len(m.Value)))
+		rec = append(rec, m.Value...)
+		rec = binary.AppendVarint(rec, 0) // headers
+		records = binary.AppendVarint(records, int64(len(rec)))
+		records = append(records, rec...)
+	}
+
+	var attributes int16
+	if compression == KafkaCompressGzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(records)
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress kafka records: %w", err)
+		}
+		records = buf.Bytes()
+		attributes = 1
+	}
+
+	// crc覆盖attributes至末尾
+	body := appendKafkaInt16(nil, attributes)
+	body = appendKafkaInt32(body, int32(len(msgs)-1)) // last_offset_delta
+	body = appendKafkaInt64(body, base)
+	body = appendKafkaInt64(body, maxTS)
+	body = appendKafkaInt64(body, -1) // producer_id
+	body = appendKafkaInt16(body, -1) // producer_epoch
+	body = appendKafkaInt32(body, -1) // base_sequence
+	body = appendKafkaInt32(body, int32(len(msgs)))
+	body = append(body, records...)
+
+	batch := appendKafkaInt64(nil, 0)                       // base_offset
+	batch = appendKafkaInt32(batch, int32(4+1+4+len(body))) // batch_length
+	batch = appendKafkaInt32(batch, -1)                     // partition_leader_epoch
+	batch = append(batch, 2)                                // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(body, crc32c))
+	return append(batch, body...), nil
+}
+
+// kafkaMurmur2 Kafka默认分区器使用的murmur2哈希
+func kafkaMurmur2(data []byte) int32 {
+	const m = 0x5bd1e995
+	n := len(data)
+	h := uint32(0x9747b28c) ^ uint32(n)
+	for i := 0; i+4 <= n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> 24
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[n&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+func appendKafkaInt16(b []byte, v int16) []byte {
+	return binary.BigEndian.AppendUint16(b, uint16(v))
+}
+
+func appendKafkaInt32(b []byte, v int32) []byte {
+	return binary.BigEndian.AppendUint32(b, uint32(v))
+}
+
+func appendKafkaInt64(b []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+func appendKafkaString(b []byte, s string) []byte {
+	b = appendKafkaInt16(b, int16(len(s)))
+	return append(b, s...)
+}
+
+// kafkaDecoder 顺序读取大端字段，出错后后续读取均返回零值
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if v := d.take(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if v := d.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if v := d.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if v := d.take(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	return string(d.take(int(d.int16())))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) bytes() []byte {
+	return d.take(int(d.int32()))
+}
+
+func (d *kafkaDecoder) int32Array() []int32 {
+	n := d.int32()
+	if n < 0 || int(n) > len(d.b)/4 {
+		if n > 0 {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	out := make([]int32, n)
+	for i := range out {
+		out[i] = d.int32()
+	}
+	return out
+}
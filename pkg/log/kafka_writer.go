@@ -0,0 +1,168 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KafkaMessage 一条待发送的Kafka消息，Key为nil时由生产者选择分区
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// KafkaProducer Kafka生产者接口，可注入其他客户端（如需要SASL/TLS时）
+// Produce返回错误时，KafkaWriter按RetryConfig重试整批消息
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, msgs []KafkaMessage) error
+	Close() error
+}
+
+// KafkaWriterConfig Kafka写入器配置
+// Brokers: 启动broker地址列表，如["kafka:9092"]；注入Producer时不使用
+// KeyField/KeyLabel: 消息key取自该字段或标签（字段优先），相同key进入同一分区；均未命中时key为空
+// Compression: 内置生产者的压缩方式，"none"（默认）或"gzip"
+// RequiredAcks: 内置生产者的acks，-1（默认，等待全部同步副本）或1（只等待leader）
+type KafkaWriterConfig struct {
+	Brokers      []string      // broker地址
+	Topic        string        // 主题
+	KeyField     string        // 作为key的字段
+	KeyLabel     string        // 作为key的标签
+	Compression  string        // 压缩方式
+	RequiredAcks int           // acks
+	ClientID     string        // 客户端ID，默认"logdashboard"
+	Producer     KafkaProducer // 注入的生产者
+	Batch        BatchConfig   // 批量配置
+	Retry        RetryConfig   // 重试配置
+	Timeout      time.Duration // 连接与请求超时，默认10秒
+}
+
+// KafkaWriter Kafka写入器，实现Writer接口
+// 日志按配置的编码器编码为消息体，后台批量发送
+
+type KafkaWriter struct {
+	cfg      KafkaWriterConfig
+	producer KafkaProducer
+	encoder  *Encoder
+	batcher  *batcher
+}
+
+// NewKafkaWriter 创建Kafka写入器，连接在首次发送时建立
+func NewKafkaWriter(c KafkaWriterConfig) (*KafkaWriter, error) {
+	if c.Topic == "" {
+		return nil, fmt.Errorf("kafka topic is required")
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	producer := c.Producer
+	if producer == nil {
+		if len(c.Brokers) == 0 {
+			return nil, fmt.Errorf("kafka brokers are required")
+		}
+		if c.Compression == "" {
+			c.Compression = KafkaCompressNone
+		}
+		if c.Compression != KafkaCompressNone && c.Compression != KafkaCompressGzip {
+			return nil, fmt.Errorf("unsupported kafka compression %q", c.Compression)
+		}
+		if c.RequiredAcks == 0 {
+			c.RequiredAcks = -1
+		}
+		if c.RequiredAcks != -1 && c.RequiredAcks != 1 {
+			return nil, fmt.Errorf("invalid kafka required acks %d", c.RequiredAcks)
+		}
+		if c.ClientID == "" {
+			c.ClientID = "logdashboard"
+		}
+		producer = newKafkaProducer(c.Brokers, c.ClientID, c.Compression, int16(c.RequiredAcks), c.Timeout)
+	}
+
+	kw := &KafkaWriter{cfg: c, producer: producer}
+	kw.batcher = newBatcher("kafka", c.Batch, c.Retry, kw.push)
+	return kw, nil
+}
+
+// SetEncoder 设置消息体的编码器
+func (kw *KafkaWriter) SetEncoder(enc *Encoder) {
+	kw.encoder = enc
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台批量发送
+func (kw *KafkaWriter) Write(entry *LogEntry) error {
+	return kw.batcher.enqueue(entry)
+}
+
+// Flush 发送队列中已有的日志
+func (kw *KafkaWriter) Flush(ctx context.Context) error {
+	return kw.batcher.flush(ctx)
+}
+
+// Close 发送剩余日志，停止后台协程并关闭生产者
+func (kw *KafkaWriter) Close() error {
+	return kw.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (kw *KafkaWriter) closeContext(ctx context.Context) error {
+	err := kw.batcher.close(ctx)
+	if cerr := kw.producer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// key 取消息key
+func (kw *KafkaWriter) key(entry *LogEntry) []byte {
+	if kw.cfg.KeyField != "" {
+		if v, ok := entry.Fields[kw.cfg.KeyField]; ok {
+			return []byte(fieldString(v))
+		}
+	}
+	if kw.cfg.KeyLabel != "" {
+		if v, ok := entry.Labels[kw.cfg.KeyLabel]; ok {
+			return []byte(v)
+		}
+	}
+	return nil
+}
+
+// push 编码并发送一批日志，部分分区失败时只重试失败的日志
+func (kw *KafkaWriter) push(batch []*LogEntry) error {
+	msgs := make([]KafkaMessage, 0, len(batch))
+	entries := make([]*LogEntry, 0, len(batch))
+	for _, entry := range batch {
+		value, err := encodeEntry(kw.encoder, entry)
+		if err != nil {
+			continue
+		}
+		msgs = append(msgs, KafkaMessage{Key: kw.key(entry), Value: []byte(value), Time: time.Unix(entry.Time, 0)})
+		entries = append(entries, entry)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kw.cfg.Timeout)
+	defer cancel()
+	err := kw.producer.Produce(ctx, kw.cfg.Topic, msgs)
+	if err == nil {
+		size := 0
+		for _, m := range msgs {
+			size += len(m.Key) + len(m.Value)
+		}
+		metricBytesSent.add(float64(size), "kafka")
+	}
+	var pe *kafkaPartialError
+	if errors.As(err, &pe) {
+		failed := make([]*LogEntry, 0, len(pe.failed))
+		for _, i := range pe.failed {
+			failed = append(failed, entries[i])
+		}
+		return &partialError{failed: failed, err: err}
+	}
+	return err
+}
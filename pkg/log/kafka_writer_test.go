@@ -0,0 +1,372 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafkaRecord 假broker收到的一条记录
+type fakeKafkaRecord struct {
+	Partition int32
+	Key       []byte
+	Value     []byte
+	Time      time.Time
+}
+
+// fakeKafkaBroker 进程内的单节点Kafka，实现Metadata v1与Produce v3，供离线测试
+type fakeKafkaBroker struct {
+	t          *testing.T
+	ln         net.Listener
+	topic      string
+	partitions int32
+
+	mu       sync.Mutex
+	records  []fakeKafkaRecord
+	failOnce map[int32]int16 // 分区下一次Produce返回的错误码
+	noLeader map[int32]int   // 分区在之后若干次Metadata响应中没有leader
+	produces int
+}
+
+func newFakeKafkaBroker(t *testing.T, topic string, partitions int32) *fakeKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeKafkaBroker{t: t, ln: ln, topic: topic, partitions: partitions, failOnce: map[int32]int16{}, noLeader: map[int32]int{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *fakeKafkaBroker) addr() string { return b.ln.Addr().String() }
+func (b *fakeKafkaBroker) close()       { b.ln.Close() }
+
+func (b *fakeKafkaBroker) received() []fakeKafkaRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeKafkaRecord{}, b.records...)
+}
+
+func (b *fakeKafkaBroker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		req := make([]byte, size)
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+		d := &kafkaDecoder{b: req}
+		apiKey, version, corr := d.int16(), d.int16(), d.int32()
+		d.nullableString() // client_id
+
+		var resp []byte
+		switch {
+		case apiKey == kafkaAPIMetadata && version == 1:
+			resp = b.metadata()
+		case apiKey == kafkaAPIProduce && version == 3:
+			resp = b.produce(d)
+		default:
+			b.t.Errorf("fake broker: unsupported api %d v%d", apiKey, version)
+			return
+		}
+		out := appendKafkaInt32(nil, int32(len(resp)+4))
+		out = appendKafkaInt32(out, corr)
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *fakeKafkaBroker) metadata() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	host, portStr, _ := net.SplitHostPort(b.addr())
+	port, _ := strconv.Atoi(portStr)
+	resp := appendKafkaInt32(nil, 1) // brokers
+	resp = appendKafkaInt32(resp, 1)
+	resp = appendKafkaString(resp, host)
+	resp = appendKafkaInt32(resp, int32(port))
+	resp = appendKafkaInt16(resp, -1) // rack
+	resp = appendKafkaInt32(resp, 1)  // controller_id
+	resp = appendKafkaInt32(resp, 1)  // topics
+	resp = appendKafkaInt16(resp, 0)
+	resp = appendKafkaString(resp, b.topic)
+	resp = append(resp, 0) // is_internal
+	resp = appendKafkaInt32(resp, b.partitions)
+	for p := int32(0); p < b.partitions; p++ {
+		if b.noLeader[p] > 0 {
+			b.noLeader[p]--
+			resp = appendKafkaInt16(resp, 5) // LEADER_NOT_AVAILABLE
+			resp = appendKafkaInt32(resp, p)
+			resp = appendKafkaInt32(resp, -1)
+			resp = appendKafkaInt32(resp, 0)
+			resp = appendKafkaInt32(resp, 0)
+			continue
+		}
+		resp = appendKafkaInt16(resp, 0)
+		resp = appendKafkaInt32(resp, p)
+		resp = appendKafkaInt32(resp, 1) // leader
+		resp = appendKafkaInt32(resp, 1) // replicas
+		resp = appendKafkaInt32(resp, 1)
+		resp = appendKafkaInt32(resp, 1) // isr
+		resp = appendKafkaInt32(resp, 1)
+	}
+	return resp
+}
+
+func (b *fakeKafkaBroker) produce(d *kafkaDecoder) []byte {
+	d.nullableString() // transactional_id
+	d.int16()          // acks
+	d.int32()          // timeout
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.produces++
+
+	var resp []byte
+	topics := d.int32()
+	resp = appendKafkaInt32(resp, topics)
+	for ; topics > 0; topics-- {
+		name := d.string()
+		resp = appendKafkaString(resp, name)
+		parts := d.int32()
+		resp = appendKafkaInt32(resp, parts)
+		for ; parts > 0; parts-- {
+			part := d.int32()
+			batch := d.bytes()
+			code, failing := b.failOnce[part]
+			if failing {
+				delete(b.failOnce, part)
+			} else {
+				recs, err := decodeKafkaRecordBatch(batch)
+				if err != nil {
+					b.t.Errorf("fake broker: %v", err)
+					code = 2
+				}
+				for _, rec := range recs {
+					rec.Partition = part
+					b.records = append(b.records, rec)
+				}
+			}
+			resp = appendKafkaInt32(resp, part)
+			resp = appendKafkaInt16(resp, code)
+			resp = appendKafkaInt64(resp, 0)
+			resp = appendKafkaInt64(resp, -1)
+		}
+	}
+	return appendKafkaInt32(resp, 0) // throttle_time_ms
+}
+
+// decodeKafkaRecordBatch 解码RecordBatch v2并校验crc
+func decodeKafkaRecordBatch(b []byte) ([]fakeKafkaRecord, error) {
+	d := &kafkaDecoder{b: b}
+	d.int64() // base_offset
+	if n := d.int32(); int(n) != len(d.b) {
+		return nil, errors.New("batch length mismatch")
+	}
+	d.int32() // partition_leader_epoch
+	if d.int8() != 2 {
+		return nil, errors.New("magic must be 2")
+	}
+	crc := uint32(d.int32())
+	if crc32.Checksum(d.b, crc32.MakeTable(crc32.Castagnoli)) != crc {
+		return nil, errors.New("crc mismatch")
+	}
+	attributes := d.int16()
+	d.int32() // last_offset_delta
+	base := d.int64()
+	d.int64()
+	d.int64()
+	d.int16()
+	d.int32()
+	count := d.int32()
+	records := d.b
+	if attributes&7 == 1 {
+		zr, err := gzip.NewReader(bytes.NewReader(records))
+		if err != nil {
+			return nil, err
+		}
+		if records, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+
+	r := bytes.NewReader(records)
+	varint := func() int64 { v, _ := binary.ReadVarint(r); return v }
+	read := func(n int64) []byte {
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n)
+		_, _ = io.ReadFull(r, buf)
+		return buf
+	}
+	var out []fakeKafkaRecord
+	for i := int32(0); i < count; i++ {
+		varint()            // length
+		_, _ = r.ReadByte() // attributes
+		ts := varint()
+		varint() // offset_delta
+		key := read(varint())
+		value := read(varint())
+		varint() // headers
+		out = append(out, fakeKafkaRecord{Key: key, Value: value, Time: time.UnixMilli(base + ts)})
+	}
+	return out, nil
+}
+
+func TestKafkaMurmur2(t *testing.T) {
+	// 与Kafka Java客户端Utils.murmur2的测试向量一致
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for in, want := range cases {
+		if got := kafkaMurmur2([]byte(in)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestKafkaWriterFakeBroker(t *testing.T) {
+	broker := newFakeKafkaBroker(t, "logs", 3)
+	defer broker.close()
+
+	kw, err := NewKafkaWriter(KafkaWriterConfig{
+		Brokers: []string{broker.addr()}, Topic: "logs", KeyLabel: "service",
+		Compression: KafkaCompressGzip, Retry: RetryConfig{Backoff: time.Millisecond}, Timeout: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 让api所在分区第一次返回NOT_LEADER_OR_FOLLOWER，只有该分区的消息被重发
+	apiPart := int32(int(kafkaMurmur2([]byte("api"))&0x7fffffff) % 3)
+	broker.mu.Lock()
+	broker.failOnce[apiPart] = 6
+	broker.mu.Unlock()
+
+	for i, svc := range []string{"api", "worker", "api", "billing"} {
+		_ = kw.Write(&LogEntry{Level: "info", Message: "m" + strconv.Itoa(i), Labels: map[string]string{"service": svc}, Time: 1700000000})
+	}
+	if err := kw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = kw.Close()
+
+	recs := broker.received()
+	if len(recs) != 4 {
+		t.Fatalf("broker received %d records, want 4 without duplicates", len(recs))
+	}
+	for _, rec := range recs {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(rec.Value, &doc); err != nil {
+			t.Fatalf("value is not json: %s", rec.Value)
+		}
+		want := int32(int(kafkaMurmur2(rec.Key)&0x7fffffff) % 3)
+		if rec.Partition != want || doc["labels"].(map[string]interface{})["service"] != string(rec.Key) {
+			t.Errorf("record %s with key %s in partition %d, want %d", rec.Value, rec.Key, rec.Partition, want)
+		}
+		if !rec.Time.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("record time = %v", rec.Time)
+		}
+	}
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if broker.produces < 2 {
+		t.Errorf("expected a retry produce request, got %d requests", broker.produces)
+	}
+}
+
+func TestKafkaKeyPartitionWithoutLeader(t *testing.T) {
+	broker := newFakeKafkaBroker(t, "logs", 3)
+	defer broker.close()
+	// api所在分区在第一次元数据中没有leader，消息须等待而不是改投其他分区
+	apiPart := int32(int(kafkaMurmur2([]byte("api"))&0x7fffffff) % 3)
+	broker.mu.Lock()
+	broker.noLeader[apiPart] = 1
+	broker.mu.Unlock()
+
+	kw, err := NewKafkaWriter(KafkaWriterConfig{
+		Brokers: []string{broker.addr()}, Topic: "logs", KeyLabel: "service",
+		Retry: RetryConfig{Backoff: time.Millisecond}, Timeout: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, svc := range []string{"api", "worker", "billing"} {
+		_ = kw.Write(&LogEntry{Level: "info", Message: svc, Labels: map[string]string{"service": svc}, Time: 1700000000})
+	}
+	if err := kw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := broker.received()
+	if len(recs) != 3 {
+		t.Fatalf("broker received %d records, want 3", len(recs))
+	}
+	for _, rec := range recs {
+		if want := int32(int(kafkaMurmur2(rec.Key)&0x7fffffff) % 3); rec.Partition != want {
+			t.Errorf("key %s in partition %d, want %d", rec.Key, rec.Partition, want)
+		}
+	}
+}
+
+// captureProducer 注入的生产者，记录收到的消息
+type captureProducer struct {
+	mu   sync.Mutex
+	msgs []KafkaMessage
+}
+
+func (p *captureProducer) Produce(ctx context.Context, topic string, msgs []KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *captureProducer) Close() error { return nil }
+
+func TestKafkaWriterInjectedProducer(t *testing.T) {
+	p := &captureProducer{}
+	kw, err := NewKafkaWriter(KafkaWriterConfig{Topic: "logs", Producer: p, KeyField: "user_id", KeyLabel: "service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kw.SetEncoder(NewEncoder(ECSEncoderConfig()))
+	_ = kw.Write(&LogEntry{Level: "info", Message: "a", Labels: map[string]string{"service": "api"}, Fields: map[string]interface{}{"user_id": 42}})
+	_ = kw.Write(&LogEntry{Level: "info", Message: "b", Labels: map[string]string{"service": "api"}})
+	_ = kw.Close()
+
+	if len(p.msgs) != 2 || string(p.msgs[0].Key) != "42" || string(p.msgs[1].Key) != "api" {
+		t.Fatalf("unexpected messages %+v", p.msgs)
+	}
+	if !bytes.Contains(p.msgs[0].Value, []byte(`"message":"a"`)) {
+		t.Errorf("value not encoded with ECS encoder: %s", p.msgs[0].Value)
+	}
+}
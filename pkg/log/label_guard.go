@@ -0,0 +1,160 @@
+package log
+
+import (
+	"sort"
+	"sync"
+)
+
+// 标签降级原因，作为log_label_demotions_total指标的reason标签
+const (
+	demoteNotAllowed = "not_allowed" // 不在白名单中
+	demoteLimit      = "limit"       // 超出标签集合数量上限
+)
+
+var metricLabelDemotions = newCounterVec("log_label_demotions_total",
+	"Labels demoted to fields by the label guard.", "reason")
+
+// LabelGuardConfig 标签基数保护配置
+// AllowLabels: 允许作为流标签的键，nil时允许全部；level标签始终保留
+// MaxLabelSets: 进程内不同标签集合的上限，默认1000，负数不限制；
+// 超出后新集合中未出现过的标签值被降级为字段，仍是新集合时只保留level
+type LabelGuardConfig struct {
+	AllowLabels  []string // 标签白名单
+	MaxLabelSets int      // 标签集合上限
+}
+
+// LabelGuard 标签基数保护处理器，实现Processor接口
+// 标签名先按Loki规则清洗为[a-zA-Z_][a-zA-Z0-9_]*，再按白名单与集合上限将多余的标签降级为字段
+type LabelGuard struct {
+	allow map[string]bool
+	max   int
+
+	mu    sync.Mutex
+	sets  map[string]bool
+	pairs map[string]bool // 已接纳集合中出现过的"键=值"
+}
+
+// NewLabelGuard 创建标签基数保护处理器
+func NewLabelGuard(c LabelGuardConfig) *LabelGuard {
+	if c.MaxLabelSets == 0 {
+		c.MaxLabelSets = 1000
+	}
+	g := &LabelGuard{max: c.MaxLabelSets, sets: map[string]bool{}, pairs: map[string]bool{}}
+	if c.AllowLabels != nil {
+		g.allow = map[string]bool{"level": true}
+		for _, k := range c.AllowLabels {
+			g.allow[SanitizeLabelName(k)] = true
+		}
+	}
+	return g
+}
+
+// SanitizeLabelName 将标签名清洗为Loki允许的[a-zA-Z_][a-zA-Z0-9_]*，非法字符替换为"_"
+func SanitizeLabelName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		// 数字开头时保留数字，加前缀
+		return "_" + name[:1] + string(b[1:])
+	}
+	return string(b)
+}
+
+// Process 实现Processor接口
+func (g *LabelGuard) Process(entry *LogEntry) (*LogEntry, bool) {
+	if len(entry.Labels) == 0 {
+		return entry, true
+	}
+	labels := make(map[string]string, len(entry.Labels))
+	var renamed []string
+	for k, v := range entry.Labels {
+		name := SanitizeLabelName(k)
+		switch {
+		case g.allow != nil && !g.allow[name]:
+			g.demote(entry, k, v, demoteNotAllowed)
+		case name != k:
+			renamed = append(renamed, k)
+		default:
+			labels[k] = v
+		}
+	}
+	// 清洗后的名称与合法标签重名时保留合法的那个
+	sort.Strings(renamed)
+	for _, k := range renamed {
+		name := SanitizeLabelName(k)
+		if _, exists := labels[name]; exists {
+			g.demote(entry, k, entry.Labels[k], demoteNotAllowed)
+			continue
+		}
+		labels[name] = entry.Labels[k]
+	}
+	entry.Labels = labels
+
+	if g.max > 0 {
+		g.limit(entry)
+	}
+	return entry, true
+}
+
+// limit 执行标签集合数量上限
+func (g *LabelGuard) limit(entry *LogEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := labelsKey(entry.Labels)
+	if g.sets[key] {
+		return
+	}
+	if len(g.sets) < g.max {
+		g.admit(key, entry.Labels)
+		return
+	}
+
+	// 降级未出现过的标签值，剩余标签通常已是已知集合
+	for k, v := range entry.Labels {
+		if k != "level" && !g.pairs[k+"="+v] {
+			g.demote(entry, k, v, demoteLimit)
+			delete(entry.Labels, k)
+		}
+	}
+	key = labelsKey(entry.Labels)
+	if g.sets[key] {
+		return
+	}
+	// 已知标签值的新组合同样会增加流数量，只保留level
+	for k, v := range entry.Labels {
+		if k != "level" {
+			g.demote(entry, k, v, demoteLimit)
+			delete(entry.Labels, k)
+		}
+	}
+	// 只含level的集合数量有限，不受上限约束
+	g.sets[labelsKey(entry.Labels)] = true
+}
+
+func (g *LabelGuard) admit(key string, labels map[string]string) {
+	g.sets[key] = true
+	for k, v := range labels {
+		g.pairs[k+"="+v] = true
+	}
+}
+
+// demote 将标签移入字段，与已有字段重名时加"labels."前缀
+func (g *LabelGuard) demote(entry *LogEntry, k, v, reason string) {
+	if entry.Fields == nil {
+		entry.Fields = map[string]interface{}{}
+	}
+	if _, exists := entry.Fields[k]; exists {
+		k = "labels." + k
+	}
+	entry.Fields[k] = v
+	metricLabelDemotions.inc(reason)
+}
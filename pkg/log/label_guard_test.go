@@ -0,0 +1,117 @@
+package log
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSanitizeLabelName(t *testing.T) {
+	cases := map[string]string{
+		"service":    "service",
+		"k8s.pod":    "k8s_pod",
+		"app-name":   "app_name",
+		"9lives":     "_9lives",
+		"":           "_",
+		"_ok_2":      "_ok_2",
+		"a b/c":      "a_b_c",
+		"http.code1": "http_code1",
+	}
+	for in, want := range cases {
+		if got := SanitizeLabelName(in); got != want {
+			t.Errorf("SanitizeLabelName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLabelGuardAllowList(t *testing.T) {
+	g := NewLabelGuard(LabelGuardConfig{AllowLabels: []string{"service", "k8s.ns"}})
+	before := metricLabelDemotions.get(demoteNotAllowed)
+	entry := &LogEntry{
+		Level:  "info",
+		Labels: map[string]string{"level": "info", "service": "api", "k8s.ns": "prod", "user_id": "42"},
+		Fields: map[string]interface{}{"user_id": "dup"},
+	}
+	out, ok := g.Process(entry)
+	if !ok {
+		t.Fatal("entry dropped")
+	}
+	want := map[string]string{"level": "info", "service": "api", "k8s_ns": "prod"}
+	if labelsKey(out.Labels) != labelsKey(want) {
+		t.Errorf("labels = %v, want %v", out.Labels, want)
+	}
+	if out.Fields["labels.user_id"] != "42" || out.Fields["user_id"] != "dup" {
+		t.Errorf("fields = %v", out.Fields)
+	}
+	if got := metricLabelDemotions.get(demoteNotAllowed) - before; got != 1 {
+		t.Errorf("demotions = %v, want 1", got)
+	}
+}
+
+func TestLabelGuardSanitizeCollision(t *testing.T) {
+	g := NewLabelGuard(LabelGuardConfig{})
+	out, _ := g.Process(&LogEntry{Labels: map[string]string{"a_b": "valid", "a.b": "renamed", "c-d": "x"}})
+	if out.Labels["a_b"] != "valid" || out.Labels["c_d"] != "x" || len(out.Labels) != 2 {
+		t.Errorf("labels = %v", out.Labels)
+	}
+	if out.Fields["a.b"] != "renamed" {
+		t.Errorf("fields = %v", out.Fields)
+	}
+}
+
+func TestLabelGuardMaxLabelSets(t *testing.T) {
+	g := NewLabelGuard(LabelGuardConfig{MaxLabelSets: 2})
+	before := metricLabelDemotions.get(demoteLimit)
+	process := func(labels map[string]string) *LogEntry {
+		out, _ := g.Process(&LogEntry{Labels: labels})
+		return out
+	}
+
+	process(map[string]string{"level": "info", "service": "api", "user": "1"})
+	process(map[string]string{"level": "info", "service": "api"})
+
+	// 新的user值被降级，剩余标签是已知集合
+	out := process(map[string]string{"level": "info", "service": "api", "user": "2"})
+	if len(out.Labels) != 2 || out.Fields["user"] != "2" {
+		t.Errorf("over limit: labels=%v fields=%v", out.Labels, out.Fields)
+	}
+	// 已接纳的集合不受影响
+	out = process(map[string]string{"level": "info", "service": "api", "user": "1"})
+	if out.Labels["user"] != "1" || len(out.Fields) != 0 {
+		t.Errorf("admitted set changed: labels=%v fields=%v", out.Labels, out.Fields)
+	}
+	// 已知值的新组合只保留level
+	out = process(map[string]string{"level": "warn", "service": "api"})
+	if len(out.Labels) != 1 || out.Labels["level"] != "warn" || out.Fields["service"] != "api" {
+		t.Errorf("new combination: labels=%v fields=%v", out.Labels, out.Fields)
+	}
+	if got := metricLabelDemotions.get(demoteLimit) - before; got != 2 {
+		t.Errorf("demotions = %v, want 2", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		process(map[string]string{"level": "info", "user": strconv.Itoa(i)})
+	}
+	if len(g.sets) > 4 {
+		t.Errorf("label sets = %d, want bounded", len(g.sets))
+	}
+}
+
+func TestLabelGuardInInit(t *testing.T) {
+	ring := NewRingWriter(10)
+	Init(Config{
+		Labels:     map[string]string{"service": "api", "request_id": "r-1"},
+		Writers:    map[string]Writer{"ring": ring},
+		Processors: []Processor{PromoteToLabels("tenant")},
+		LabelGuard: &LabelGuardConfig{AllowLabels: []string{"service"}},
+	})
+	defer Init(Config{})
+
+	Info("hello", NewField("tenant", "t1"))
+	e := ring.Entries(RingFilter{})[0]
+	if len(e.Labels) != 2 || e.Labels["service"] != "api" || e.Labels["level"] != "info" {
+		t.Errorf("labels = %v", e.Labels)
+	}
+	if e.Fields["request_id"] != "r-1" || e.Fields["tenant"] != "t1" {
+		t.Errorf("fields = %v", e.Fields)
+	}
+}
@@ -1,6 +1,12 @@
 package log
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 )
@@ -8,9 +14,14 @@ import (
 // 日志主入口，暴露统一API
 
 var (
-	loggers []Writer
-	cfg     Config
-	mu      sync.Mutex
+	loggers     []Writer
+	loggerNames []string      // 与loggers一一对应的名称，供路由规则引用
+	loggerProcs [][]Processor // 与loggers一一对应的写入器处理器链
+	routes      *router
+	metricRules []*metricRule
+	initErrs    []error // 最近一次Init的配置错误
+	cfg         Config
+	mu          sync.Mutex
 )
 
 // 日志级别优先级
@@ -21,30 +32,242 @@ var levelPriority = map[string]int{
 	"error": 3,
 }
 
+// LevelPriority 返回级别的优先级（debug最低，error最高），未知级别返回false
+func LevelPriority(level string) (int, bool) {
+	p, ok := levelPriority[level]
+	return p, ok
+}
+
 // Init 初始化日志模块，配置本地文件、Loki、标签等
+// 被替换的文件写入器在创建新写入器前关闭，其余被替换的写入器在后台发送剩余日志后关闭，需要等待时先调用Shutdown
+// 配置错误与创建失败的写入器不会中断初始化，可通过InitError获取
 func Init(c Config) {
+	// 旧处理器链中待输出的汇总日志在替换前经旧链写入旧写入器，与Shutdown一致
+	flushProcessors()
+
 	mu.Lock()
 	defer mu.Unlock()
+	initErrs = nil
+
+	// 只有Config.Writers中的实例会被复用，其余旧写入器均被替换
+	reused := make([]Writer, 0, len(c.Writers))
+	for _, w := range c.Writers {
+		reused = append(reused, w)
+	}
+	replaced, replacedNames := replacedWriters(loggers, loggerNames, reused)
+	// 新写入器可能打开同一路径并接续审计哈希链，旧文件写入器须先写完检查点并关闭
+	replaced, replacedNames, err := closeFileWriters(replaced, replacedNames)
+	if err != nil {
+		initErrs = append(initErrs, err)
+	}
+
 	cfg = c
 	loggers = []Writer{}
+	loggerNames = nil
+	loggerProcs = nil
 
 	// 设置默认级别
 	if cfg.Level == "" {
 		cfg.Level = "info"
 	}
 
+	enc := NewEncoder(c.Encoder)
+
+	// 组装全局处理器链：采样与去重最先执行，尽早丢弃重复日志；脱敏在自定义处理器之前；
+	// 标签保护最后执行，PromoteToLabels等添加的标签同样受约束
+	var chain []Processor
+	if c.Sampling != nil {
+		chain = append(chain, NewSampler(*c.Sampling))
+	}
+	if c.Dedup != nil {
+		chain = append(chain, NewDeduper(*c.Dedup))
+	}
+	if c.Redact != nil {
+		r, err := NewRedactor(*c.Redact)
+		if err != nil {
+			// 脱敏配置无效时丢弃全部日志，避免敏感信息以明文发往各写入器
+			initErrs = append(initErrs, fmt.Errorf("failed to init redaction, all entries are dropped: %w", err))
+			chain = append(chain, redactBlocker{})
+		} else {
+			chain = append(chain, r)
+		}
+	}
+	chain = append(chain, c.Processors...)
+	if c.LabelGuard != nil {
+		chain = append(chain, NewLabelGuard(*c.LabelGuard))
+	}
+	cfg.Processors = chain
+
+	// writerFailed 记录创建失败的写入器，该写入器不会被注册
+	writerFailed := func(name string, err error) {
+		initErrs = append(initErrs, fmt.Errorf("failed to init writer %s: %w", name, err))
+	}
+
 	// 初始化本地文件写入器
 	if c.FilePath != "" {
 		fw, err := NewFileWriter(c.FilePath)
-		if err == nil {
-			loggers = append(loggers, fw)
+		if err != nil {
+			writerFailed("file", err)
+		} else {
+			fw.SetEncoder(enc)
+			addWriter("file", fw)
+		}
+	}
+	// 初始化按级别分流的文件写入器
+	for _, fc := range c.Files {
+		fw, err := NewFileWriterWithConfig(fc)
+		if err != nil {
+			writerFailed(fileWriterName(fc), err)
+		} else {
+			fw.SetEncoder(enc)
+			addWriter(fileWriterName(fc), fw)
+		}
+	}
+	// 初始化控制台写入器
+	if c.Console != nil {
+		cw := NewConsoleWriter(*c.Console)
+		cw.SetEncoder(enc)
+		addWriter("console", cw)
+	}
+	// 初始化syslog写入器
+	if c.Syslog != nil {
+		sw, err := NewSyslogWriter(*c.Syslog)
+		if err != nil {
+			writerFailed("syslog", err)
+		} else {
+			addWriter("syslog", sw)
 		}
 	}
 	// 初始化Loki写入器
 	if c.LokiURL != "" {
-		lw := NewLokiWriter(c.LokiURL, c.Labels)
-		loggers = append(loggers, lw)
+		// Labels已合并到每条日志中，此处不再重复合并，以免绕过处理器与标签保护
+		lw := NewLokiWriterWithConfig(LokiWriterConfig{URL: c.LokiURL, MetadataFields: c.LokiMetadata})
+		lw.SetEncoder(enc)
+		addWriter("loki", lw)
+	}
+	// 初始化阿里云SLS写入器
+	if c.SLS != nil {
+		sw, err := NewSLSWriter(*c.SLS)
+		if err != nil {
+			writerFailed("sls", err)
+		} else {
+			addWriter("sls", sw)
+		}
+	}
+	// 初始化OTLP写入器
+	if c.OTLP != nil {
+		ow, err := NewOTLPWriter(*c.OTLP)
+		if err != nil {
+			writerFailed("otlp", err)
+		} else {
+			addWriter("otlp", ow)
+		}
+	}
+	// 初始化Elasticsearch/OpenSearch写入器
+	if c.Elastic != nil {
+		ew, err := NewElasticWriter(*c.Elastic)
+		if err != nil {
+			writerFailed("elastic", err)
+		} else {
+			addWriter("elastic", ew)
+		}
+	}
+	// 初始化Fluentd/Fluent Bit forward写入器
+	if c.Forward != nil {
+		fw, err := NewForwardWriter(*c.Forward)
+		if err != nil {
+			writerFailed("forward", err)
+		} else {
+			addWriter("forward", fw)
+		}
+	}
+	// 初始化Graylog GELF写入器
+	if c.GELF != nil {
+		gw, err := NewGELFWriter(*c.GELF)
+		if err != nil {
+			writerFailed("gelf", err)
+		} else {
+			addWriter("gelf", gw)
+		}
+	}
+	// 初始化告警Webhook写入器
+	if c.Webhook != nil {
+		ww, err := NewWebhookWriter(*c.Webhook)
+		if err != nil {
+			writerFailed("webhook", err)
+		} else {
+			addWriter("webhook", ww)
+		}
+	}
+	// 初始化Kafka写入器
+	if c.Kafka != nil {
+		kw, err := NewKafkaWriter(*c.Kafka)
+		if err != nil {
+			writerFailed("kafka", err)
+		} else {
+			kw.SetEncoder(enc)
+			addWriter("kafka", kw)
+		}
+	}
+	// 添加自定义写入器
+	names := make([]string, 0, len(c.Writers))
+	for name := range c.Writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w := c.Writers[name]
+		if es, ok := w.(encoderSetter); ok {
+			es.SetEncoder(enc)
+		}
+		addWriter(name, w)
+	}
+	// 编译路由规则，无效的规则被忽略并记录错误（可先用ValidateRoutes检查）
+	if routes, err = newRouter(c.Routes, loggerNames); err != nil {
+		initErrs = append(initErrs, err)
+	}
+
+	// 替换日志派生指标，无效的规则被忽略（可先用ValidateMetricRules检查）
+	releaseMetricRules(metricRules)
+	metricRules = newMetricRules(c.Metrics)
+
+	// 去重等处理器的汇总日志从其在链中的位置继续处理
+	wireEmitters(cfg.Processors, deliver)
+	for i, w := range loggers {
+		wireEmitters(loggerProcs[i], func(entry *LogEntry) { _ = w.Write(entry) })
+	}
+
+	// 关闭其余被替换的写入器，避免连接与后台协程泄漏
+	if len(replaced) > 0 {
+		go closeWriters(context.Background(), replaced, replacedNames)
+	}
+}
+
+// fileWriterName 文件写入器的名称，默认为路径
+func fileWriterName(fc FileWriterConfig) string {
+	if fc.Name != "" {
+		return fc.Name
 	}
+	return fc.Path
+}
+
+// InitError 返回最近一次Init中的配置错误，无错误时为nil
+func InitError() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return errors.Join(initErrs...)
+}
+
+// addWriter 以名称注册写入器
+func addWriter(name string, w Writer) {
+	loggers = append(loggers, w)
+	loggerNames = append(loggerNames, name)
+	chain := cfg.WriterProcessors[name]
+	if rl, ok := cfg.RateLimits[name]; ok {
+		// 限流放在写入器处理器链最后，只计入真正发往写入器的日志
+		chain = append(append([]Processor{}, chain...), NewRateLimiter(name, rl))
+	}
+	loggerProcs = append(loggerProcs, chain)
 }
 
 // Debug 打印Debug级别日志
@@ -95,6 +318,7 @@ func logWithLevel(level, msg string, fields ...Field) {
 	if len(loggers) == 0 {
 		return
 	}
+	metricEntries.inc(level)
 
 	// 合并标签
 	labels := map[string]string{}
@@ -119,9 +343,58 @@ func logWithLevel(level, msg string, fields ...Field) {
 		Fields:  extraFields,
 		Time:    time.Now().Unix(),
 	}
+	if cfg.AddCaller {
+		entry.Caller = caller(3)
+	}
+
+	// 日志派生指标在处理器之前统计，不受采样、去重与标签保护影响
+	for _, mr := range metricRules {
+		mr.observe(entry)
+	}
+
+	// 执行全局处理器链
+	entry, ok := runProcessors(cfg.Processors, entry)
+	if !ok {
+		return
+	}
+
+	deliver(entry)
+}
+
+// deliver 按路由规则分发，未配置规则或未匹配任何规则时分发到所有Writer
+func deliver(entry *LogEntry) {
+	targets := routes.targets(entry)
+	if targets == nil {
+		for i := range loggers {
+			dispatch(i, entry)
+		}
+		return
+	}
+	for _, i := range targets {
+		dispatch(i, entry)
+	}
+}
+
+// dispatch 执行写入器的处理器链后写入，处理器作用于日志的深拷贝，嵌套的map与切片不会影响其他写入器
+func dispatch(i int, entry *LogEntry) {
+	if chain := loggerProcs[i]; len(chain) > 0 {
+		var ok bool
+		if entry, ok = runProcessors(chain, entry.Clone()); !ok {
+			return
+		}
+	}
+	if err := loggers[i].Write(entry); err != nil {
+		metricWriteErrors.inc(loggerNames[i])
+		return
+	}
+	metricWriterEntries.inc(loggerNames[i])
+}
 
-	// 分发到所有Writer
-	for _, w := range loggers {
-		_ = w.Write(entry)
+// caller 返回调用栈上第skip层的"目录/文件:行号"
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
 	}
+	return fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line)
 }
@@ -1,6 +1,8 @@
 package log
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -86,3 +88,25 @@ func TestFieldFunc(t *testing.T) {
 	// 测试向后兼容的FieldFunc
 	Info("测试FieldFunc函数", FieldFunc("key1", "value1"), FieldFunc("key2", 42))
 }
+
+func TestInitReportsWriterErrors(t *testing.T) {
+	dir := t.TempDir()
+	ring := NewRingWriter(10)
+	Init(Config{
+		Files: []FileWriterConfig{
+			{Name: "audit", Path: filepath.Join(dir, "missing", "audit.log")},
+			{Name: "local", Path: filepath.Join(dir, "app.log"), MinLevel: "verbose"},
+		},
+		Writers: map[string]Writer{"ring": ring},
+	})
+	defer Init(Config{})
+
+	err := InitError()
+	if err == nil || !strings.Contains(err.Error(), "writer audit") || !strings.Contains(err.Error(), "writer local") {
+		t.Errorf("InitError = %v", err)
+	}
+	Info("still logged")
+	if ring.Len() != 1 {
+		t.Error("healthy writers must stay registered")
+	}
+}
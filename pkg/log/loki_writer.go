@@ -2,10 +2,13 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,81 +17,195 @@ import (
 
 type lokiStream struct {
 	Stream map[string]string `json:"stream"`
-	Values [][2]string       `json:"values"`
+	Values []lokiValue       `json:"values"`
+}
+
+// lokiValue values中的一项，编码为[timestamp, line]或带结构化元数据的[timestamp, line, {metadata}]
+type lokiValue struct {
+	ts       string
+	line     string
+	metadata map[string]string
+}
+
+// MarshalJSON 编码为JSON数组
+func (v lokiValue) MarshalJSON() ([]byte, error) {
+	if len(v.metadata) == 0 {
+		return json.Marshal([2]string{v.ts, v.line})
+	}
+	return json.Marshal([3]interface{}{v.ts, v.line, v.metadata})
 }
 
 type lokiPayload struct {
 	Streams []lokiStream `json:"streams"`
 }
 
+// LokiWriterConfig Loki写入器配置
+// URL: Loki推送地址
+// Labels: 附加到每个流的标签
+// MetadataFields: 作为结构化元数据（Loki 2.9+，需启用allow_structured_metadata）发送的字段，
+// 可在LogQL中直接按| trace_id="..."过滤而无需解析日志行；为空时不发送
+// StripMetadata: 为true时结构化元数据字段不再重复写入日志行
+// Batch/Retry: 批量发送与重试配置
+type LokiWriterConfig struct {
+	URL            string            // 推送地址
+	Labels         map[string]string // 流标签
+	MetadataFields []string          // 结构化元数据字段
+	StripMetadata  bool              // 从日志行中移除元数据字段
+	Batch          BatchConfig       // 批量配置
+	Retry          RetryConfig       // 重试配置
+	Timeout        time.Duration     // 单次请求超时，默认10秒
+}
+
+// DefaultLokiMetadataFields 常用的高基数关联字段，适合作为结构化元数据而非标签
+var DefaultLokiMetadataFields = []string{"trace_id", "request_id", "user_id"}
+
 type LokiWriter struct {
 	lokiURL    string
 	labels     map[string]string
+	metadata   []string
+	strip      bool
+	encoder    *Encoder
 	httpClient *http.Client
+	batcher    *batcher
 }
 
 // NewLokiWriter 创建Loki写入器
 func NewLokiWriter(lokiURL string, labels map[string]string) *LokiWriter {
-	return &LokiWriter{
-		lokiURL: lokiURL,
-		labels:  labels,
+	return NewLokiWriterWithConfig(LokiWriterConfig{URL: lokiURL, Labels: labels})
+}
+
+// NewLokiWriterWithConfig 按配置创建Loki写入器
+func NewLokiWriterWithConfig(c LokiWriterConfig) *LokiWriter {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	lw := &LokiWriter{
+		lokiURL:  c.URL,
+		labels:   c.Labels,
+		metadata: c.MetadataFields,
+		strip:    c.StripMetadata,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: c.Timeout,
 		},
 	}
+	lw.batcher = newBatcher("loki", c.Batch, c.Retry, lw.push)
+	return lw
+}
+
+// SetEncoder 设置推送日志行的编码器
+func (lw *LokiWriter) SetEncoder(enc *Encoder) {
+	lw.encoder = enc
 }
 
+// Write 实现Writer接口，日志进入发送队列后由后台批量推送
 func (lw *LokiWriter) Write(entry *LogEntry) error {
-	// 使用纳秒级时间戳，Loki要求纳秒级精度
-	ts := strconv.FormatInt(time.Unix(entry.Time, 0).UnixNano(), 10)
+	return lw.batcher.enqueue(entry)
+}
 
-	// 组装日志内容
-	line, err := FormatLogEntry(entry)
-	if err != nil {
-		return fmt.Errorf("failed to format log entry: %w", err)
-	}
+// Flush 推送队列中已有的日志
+func (lw *LokiWriter) Flush(ctx context.Context) error {
+	return lw.batcher.flush(ctx)
+}
 
-	// Loki的values字段是[[timestamp, line]]
-	values := [][2]string{{ts, line}}
+// Close 推送剩余日志并停止后台协程
+func (lw *LokiWriter) Close() error {
+	return lw.closeContext(context.Background())
+}
 
-	// 合并标签
-	labels := map[string]string{}
-	for k, v := range lw.labels {
-		labels[k] = v
-	}
-	for k, v := range entry.Labels {
-		labels[k] = v
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (lw *LokiWriter) closeContext(ctx context.Context) error {
+	return lw.batcher.close(ctx)
+}
+
+// push 将一批日志按标签集合分组为流并推送
+func (lw *LokiWriter) push(batch []*LogEntry) error {
+	streams := map[string]*lokiStream{}
+	var order []string
+	for _, entry := range batch {
+		// 使用纳秒级时间戳，Loki要求纳秒级精度
+		ts := strconv.FormatInt(time.Unix(entry.Time, 0).UnixNano(), 10)
+
+		// 提取结构化元数据
+		metadata, lineEntry := lw.extractMetadata(entry)
+
+		// 组装日志内容
+		line, err := encodeEntry(lw.encoder, lineEntry)
+		if err != nil {
+			return permanent(fmt.Errorf("failed to format log entry: %w", err))
+		}
+
+		// 合并标签
+		labels := map[string]string{}
+		for k, v := range lw.labels {
+			labels[k] = v
+		}
+		for k, v := range entry.Labels {
+			labels[k] = v
+		}
+
+		key := labelsKey(labels)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			order = append(order, key)
+		}
+		// Loki的values字段是[[timestamp, line]]，带结构化元数据时为[[timestamp, line, {metadata}]]
+		s.Values = append(s.Values, lokiValue{ts: ts, line: line, metadata: metadata})
 	}
 
-	payload := lokiPayload{
-		Streams: []lokiStream{{
-			Stream: labels,
-			Values: values,
-		}},
+	payload := lokiPayload{}
+	for _, key := range order {
+		payload.Streams = append(payload.Streams, *streams[key])
 	}
+	return lw.pushToLoki(payload)
+}
 
-	// 重试推送
-	maxRetries := 3
-	for i := 0; i < maxRetries; i++ {
-		err := lw.pushToLoki(payload)
-		if err == nil {
-			return nil
+// extractMetadata 取出作为结构化元数据的字段，StripMetadata时返回不含这些字段的日志副本用于编码
+func (lw *LokiWriter) extractMetadata(entry *LogEntry) (map[string]string, *LogEntry) {
+	var metadata map[string]string
+	for _, k := range lw.metadata {
+		v, ok := entry.Fields[k]
+		if !ok {
+			continue
 		}
-
-		// 如果不是最后一次重试，等待一下再重试
-		if i < maxRetries-1 {
-			time.Sleep(time.Duration(i+1) * time.Second)
+		if metadata == nil {
+			metadata = map[string]string{}
 		}
+		metadata[k] = fieldString(v)
 	}
+	if metadata == nil || !lw.strip {
+		return metadata, entry
+	}
+	stripped := entry.Clone()
+	for k := range metadata {
+		delete(stripped.Fields, k)
+	}
+	return metadata, stripped
+}
 
-	return fmt.Errorf("failed to push to Loki after %d retries: %w", maxRetries, err)
+// labelsKey 标签集合的规范化字符串，用于分组
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+		b.WriteByte(',')
+	}
+	return b.String()
 }
 
 // pushToLoki 推送日志到Loki
 func (lw *LokiWriter) pushToLoki(payload lokiPayload) error {
 	b, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return permanent(fmt.Errorf("failed to marshal payload: %w", err))
 	}
 
 	resp, err := lw.httpClient.Post(lw.lokiURL, "application/json", bytes.NewReader(b))
@@ -98,8 +215,17 @@ func (lw *LokiWriter) pushToLoki(payload lokiPayload) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("Loki returned status code %d", resp.StatusCode)
+		err := fmt.Errorf("Loki returned status code %d", resp.StatusCode)
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
 	}
-
+	metricBytesSent.add(float64(len(b)), "loki")
 	return nil
 }
+
+// retryableStatus 限流与服务端错误可以重试，其他4xx错误重试无意义
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func lokiTestServer(t *testing.T) (*httptest.Server, chan []byte) {
+	t.Helper()
+	bodies := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, bodies
+}
+
+func TestLokiWriterStructuredMetadata(t *testing.T) {
+	srv, bodies := lokiTestServer(t)
+	lw := NewLokiWriterWithConfig(LokiWriterConfig{
+		URL:            srv.URL,
+		Labels:         map[string]string{"service": "api"},
+		MetadataFields: DefaultLokiMetadataFields,
+		StripMetadata:  true,
+	})
+	defer lw.Close()
+
+	entry := &LogEntry{
+		Level:   "info",
+		Message: "request done",
+		Labels:  map[string]string{"level": "info"},
+		Fields:  map[string]interface{}{"trace_id": "abc", "user_id": 42, "status": 200},
+		Time:    1700000000,
+	}
+	lw.Write(entry)
+	lw.Write(&LogEntry{Level: "info", Message: "plain", Labels: map[string]string{"level": "info"}, Time: 1700000001})
+	if err := lw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(<-bodies, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload.Streams) != 1 || payload.Streams[0].Stream["service"] != "api" {
+		t.Fatalf("streams = %+v", payload.Streams)
+	}
+	values := payload.Streams[0].Values
+	if len(values) != 2 || len(values[0]) != 3 || len(values[1]) != 2 {
+		t.Fatalf("values = %s", values)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(values[0][2], &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 2 || metadata["trace_id"] != "abc" || metadata["user_id"] != "42" {
+		t.Errorf("metadata = %v", metadata)
+	}
+	var line string
+	json.Unmarshal(values[0][1], &line)
+	var decoded struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	json.Unmarshal([]byte(line), &decoded)
+	if _, ok := decoded.Fields["trace_id"]; ok || decoded.Fields["status"] != float64(200) {
+		t.Errorf("line = %s", line)
+	}
+	if _, ok := entry.Fields["trace_id"]; !ok {
+		t.Error("StripMetadata must not modify the caller's entry")
+	}
+}
@@ -0,0 +1,74 @@
+package log
+
+import "encoding/binary"
+
+// LZ4块格式压缩（无帧头），用于SLS的x-log-compresstype: lz4
+// 采用单哈希表贪心匹配，压缩率略低于参考实现但输出完全兼容
+const (
+	lz4MinMatch     = 4
+	lz4LastLiterals = 5  // 块末尾至少保留5字节字面量
+	lz4MFLimit      = 12 // 最后一个匹配必须在距末尾12字节之前开始
+	lz4HashLog      = 16
+	lz4MaxOffset    = 65535
+)
+
+// lz4CompressBlock 压缩src为LZ4块
+func lz4CompressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(src)/255+16)
+	if len(src) < lz4MFLimit+1 {
+		return lz4AppendSequence(dst, src, 0, 0)
+	}
+
+	var table [1 << lz4HashLog]int32 // 存储位置+1，0表示空
+	anchor := 0
+	limit := len(src) - lz4MFLimit
+	for i := 0; i < limit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		mlen := lz4MinMatch
+		for i+mlen < len(src)-lz4LastLiterals && src[ref+mlen] == src[i+mlen] {
+			mlen++
+		}
+		dst = lz4AppendSequence(dst, src[anchor:i], i-ref, mlen)
+		i += mlen
+		anchor = i
+	}
+	return lz4AppendSequence(dst, src[anchor:], 0, 0)
+}
+
+// lz4AppendSequence 写入一个序列；mlen为0时为只有字面量的最后一个序列
+func lz4AppendSequence(dst, literals []byte, offset, mlen int) []byte {
+	litLen := len(literals)
+	token := byte(min(litLen, 15)) << 4
+	if mlen > 0 {
+		token |= byte(min(mlen-lz4MinMatch, 15))
+	}
+	dst = append(dst, token)
+	if litLen >= 15 {
+		dst = lz4AppendLength(dst, litLen-15)
+	}
+	dst = append(dst, literals...)
+	if mlen == 0 {
+		return dst
+	}
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(offset))
+	if mlen-lz4MinMatch >= 15 {
+		dst = lz4AppendLength(dst, mlen-lz4MinMatch-15)
+	}
+	return dst
+}
+
+func lz4AppendLength(dst []byte, n int) []byte {
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
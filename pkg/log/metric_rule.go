@@ -0,0 +1,238 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// 日志派生指标的类型
+const (
+	MetricCounter   = "counter"   // 匹配的日志条数
+	MetricHistogram = "histogram" // 匹配日志中ValueField字段的数值分布
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MetricRule 日志派生指标规则，匹配的日志在进程内计入Prometheus指标，随MetricsHandler输出
+// Name: 指标名，须匹配[a-zA-Z_:][a-zA-Z0-9_:]*且不与内置指标重名
+// Type: "counter"（默认）或"histogram"
+// Levels/Labels/Fields: 与RouteRule相同的匹配条件
+// Message: Message的正则，须完整匹配，为空时不限制
+// LabelKeys: 作为指标标签的键，取值先查日志标签再查字段，缺失时为空字符串；须避免高基数的键
+// ValueField: histogram观测的字段，支持数字、数字字符串与time.Duration（秒），无法解析时不计入
+// Buckets: histogram的桶，须升序，默认与log_push_duration_seconds相同
+type MetricRule struct {
+	Name       string            // 指标名
+	Help       string            // 说明
+	Type       string            // 指标类型
+	Levels     []string          // 匹配的级别
+	Message    string            // Message正则
+	Labels     map[string]string // 标签值正则
+	Fields     map[string]string // 字段值正则
+	LabelKeys  []string          // 指标标签
+	ValueField string            // histogram观测的字段
+	Buckets    []float64         // histogram的桶
+}
+
+// metricRule 编译后的日志派生指标规则
+type metricRule struct {
+	match      route
+	message    *regexp.Regexp
+	keys       []string
+	valueField string
+	counter    *counterVec
+	histogram  *histogramVec
+}
+
+// ValidateMetricRules 检查日志派生指标规则是否有效
+func ValidateMetricRules(rules []MetricRule) error {
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		if seen[rule.Name] {
+			return fmt.Errorf("invalid metric rule %d: duplicate metric name %q", i, rule.Name)
+		}
+		seen[rule.Name] = true
+		if _, err := compileMetricRule(rule); err != nil {
+			return fmt.Errorf("invalid metric rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// compileMetricRule 编译规则，创建的指标尚未注册
+func compileMetricRule(rule MetricRule) (*metricRule, error) {
+	if !metricNamePattern.MatchString(rule.Name) {
+		return nil, fmt.Errorf("invalid metric name %q", rule.Name)
+	}
+	if builtinMetric(rule.Name) {
+		return nil, fmt.Errorf("metric name %q is reserved", rule.Name)
+	}
+	match, err := compileRoute(RouteRule{Levels: rule.Levels, Labels: rule.Labels, Fields: rule.Fields}, nil)
+	if err != nil {
+		return nil, err
+	}
+	mr := &metricRule{match: match, valueField: rule.ValueField}
+	if rule.Message != "" {
+		if mr.message, err = regexp.Compile("^(?:" + rule.Message + ")$"); err != nil {
+			return nil, fmt.Errorf("invalid message regexp: %w", err)
+		}
+	}
+	labels := make([]string, len(rule.LabelKeys))
+	seen := map[string]bool{}
+	for i, k := range rule.LabelKeys {
+		labels[i] = SanitizeLabelName(k)
+		if seen[labels[i]] || (labels[i] == "le" && rule.Type == MetricHistogram) {
+			return nil, fmt.Errorf("duplicate or reserved metric label %q", labels[i])
+		}
+		seen[labels[i]] = true
+	}
+	mr.keys = rule.LabelKeys
+	help := rule.Help
+	if help == "" {
+		help = "Derived from log entries."
+	}
+
+	switch rule.Type {
+	case "", MetricCounter:
+		mr.counter = &counterVec{name: rule.Name, help: help, labels: labels, series: map[string]*counterSeries{}}
+	case MetricHistogram:
+		if rule.ValueField == "" {
+			return nil, fmt.Errorf("histogram %q requires ValueField", rule.Name)
+		}
+		buckets := rule.Buckets
+		if len(buckets) == 0 {
+			buckets = defaultDurationBuckets
+		}
+		for i := 1; i < len(buckets); i++ {
+			if buckets[i] <= buckets[i-1] {
+				return nil, fmt.Errorf("histogram %q buckets must be increasing", rule.Name)
+			}
+		}
+		mr.histogram = &histogramVec{name: rule.Name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", rule.Type)
+	}
+	return mr, nil
+}
+
+// derivedCollectors 由规则注册的指标，其余已注册的指标均为内置指标
+var derivedCollectors = map[collector]bool{}
+
+// builtinMetric 是否与内置指标重名
+func builtinMetric(name string) bool {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	for _, c := range collectors {
+		if !derivedCollectors[c] && c.metricName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// collector 返回规则对应的指标
+func (mr *metricRule) collector() collector {
+	if mr.histogram != nil {
+		return mr.histogram
+	}
+	return mr.counter
+}
+
+// observe 日志匹配时计入指标
+func (mr *metricRule) observe(entry *LogEntry) {
+	if !mr.match.match(entry) {
+		return
+	}
+	if mr.message != nil && !mr.message.MatchString(entry.Message) {
+		return
+	}
+	values := make([]string, len(mr.keys))
+	for i, k := range mr.keys {
+		if v, ok := entry.Labels[k]; ok {
+			values[i] = v
+		} else if v, ok := entry.Fields[k]; ok {
+			values[i] = fieldString(v)
+		}
+	}
+	if mr.counter != nil {
+		mr.counter.inc(values...)
+		return
+	}
+	if v, ok := numericValue(entry.Fields[mr.valueField]); ok {
+		mr.histogram.observe(v, values...)
+	}
+}
+
+// numericValue 将字段值转为float64
+func numericValue(v interface{}) (float64, bool) {
+	switch tv := v.(type) {
+	case int:
+		return float64(tv), true
+	case int8:
+		return float64(tv), true
+	case int16:
+		return float64(tv), true
+	case int32:
+		return float64(tv), true
+	case int64:
+		return float64(tv), true
+	case uint:
+		return float64(tv), true
+	case uint8:
+		return float64(tv), true
+	case uint16:
+		return float64(tv), true
+	case uint32:
+		return float64(tv), true
+	case uint64:
+		return float64(tv), true
+	case float32:
+		return float64(tv), true
+	case float64:
+		return tv, true
+	case time.Duration:
+		return tv.Seconds(), true
+	case json.Number:
+		f, err := tv.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(tv, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// newMetricRules 编译并注册规则的指标，忽略无效规则与重名规则
+func newMetricRules(rules []MetricRule) []*metricRule {
+	var out []*metricRule
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if seen[rule.Name] {
+			continue
+		}
+		mr, err := compileMetricRule(rule)
+		if err != nil {
+			continue
+		}
+		seen[rule.Name] = true
+		register(mr.collector())
+		metricsMu.Lock()
+		derivedCollectors[mr.collector()] = true
+		metricsMu.Unlock()
+		out = append(out, mr)
+	}
+	return out
+}
+
+// releaseMetricRules 注销规则的指标，Init替换规则时调用
+func releaseMetricRules(rules []*metricRule) {
+	for _, mr := range rules {
+		unregister(mr.collector())
+		metricsMu.Lock()
+		delete(derivedCollectors, mr.collector())
+		metricsMu.Unlock()
+	}
+}
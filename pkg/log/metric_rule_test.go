@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateMetricRules(t *testing.T) {
+	bad := []MetricRule{
+		{Name: "1bad"},
+		{Name: "log_entries_total"},
+		{Name: "x", Type: "gauge"},
+		{Name: "x", Type: MetricHistogram},
+		{Name: "x", Type: MetricHistogram, ValueField: "d", Buckets: []float64{1, 1}},
+		{Name: "x", Levels: []string{"fatal"}},
+		{Name: "x", Message: "("},
+		{Name: "x", LabelKeys: []string{"a.b", "a_b"}},
+	}
+	for _, rule := range bad {
+		if err := ValidateMetricRules([]MetricRule{rule}); err == nil {
+			t.Errorf("rule %+v should be invalid", rule)
+		}
+	}
+	if err := ValidateMetricRules([]MetricRule{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Error("duplicate names should be invalid")
+	}
+	if err := ValidateMetricRules([]MetricRule{{Name: "app_errors_total", Levels: []string{"error"}, LabelKeys: []string{"component"}}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMetricRulesInInit(t *testing.T) {
+	rules := []MetricRule{
+		{Name: "app_errors_total", Levels: []string{"error"}, LabelKeys: []string{"component"}},
+		{
+			Name:       "app_request_duration_ms",
+			Type:       MetricHistogram,
+			Message:    "request done",
+			ValueField: "duration_ms",
+			Buckets:    []float64{10, 100, 1000},
+			LabelKeys:  []string{"route"},
+		},
+	}
+	Init(Config{
+		Labels:   map[string]string{"component": "signer"},
+		Writers:  map[string]Writer{"ring": NewRingWriter(10)},
+		Metrics:  rules,
+		Sampling: &SamplerConfig{First: 1},
+	})
+	defer Init(Config{})
+
+	Error("sign failed")
+	Error("sign failed") // 被采样丢弃的日志仍计入
+	Info("request done", NewField("duration_ms", 42), NewField("route", "/sign"))
+	Info("request done", NewField("duration_ms", "250"), NewField("route", "/sign"))
+	Info("request done", NewField("duration_ms", 5*time.Millisecond), NewField("route", "/health"))
+	Info("request done", NewField("route", "/sign")) // 缺少数值，不计入
+
+	var buf bytes.Buffer
+	WriteMetrics(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE app_errors_total counter\n",
+		`app_errors_total{component="signer"} 2`,
+		"# TYPE app_request_duration_ms histogram\n",
+		`app_request_duration_ms_bucket{route="/sign",le="100"} 1`,
+		`app_request_duration_ms_bucket{route="/sign",le="1000"} 2`,
+		`app_request_duration_ms_count{route="/sign"} 2`,
+		`app_request_duration_ms_sum{route="/sign"} 292`,
+		`app_request_duration_ms_count{route="/health"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+
+	// 重新Init后旧规则的指标被注销，同名规则可重新注册
+	Init(Config{Writers: map[string]Writer{"ring": NewRingWriter(10)}, Metrics: rules[:1]})
+	buf.Reset()
+	WriteMetrics(&buf)
+	if strings.Contains(buf.String(), "app_request_duration_ms") {
+		t.Error("replaced rule still exported")
+	}
+	if strings.Contains(buf.String(), `app_errors_total{`) || !strings.Contains(buf.String(), "# TYPE app_errors_total counter") {
+		t.Errorf("re-registered rule should start empty:\n%s", buf.String())
+	}
+}
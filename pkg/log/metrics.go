@@ -0,0 +1,303 @@
+package log
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 日志管道自身的指标，进程内累计，不随Init重置
+// 通过MetricsHandler以Prometheus文本格式暴露，无需引入client_golang
+
+var (
+	metricEntries = newCounterVec("log_entries_total",
+		"Log entries accepted by level, before processors.", "level")
+	metricWriterEntries = newCounterVec("log_writer_entries_total",
+		"Entries handed to each writer.", "writer")
+	metricWriteErrors = newCounterVec("log_write_errors_total",
+		"Errors returned by writer Write calls.", "writer")
+	metricDropped = newCounterVec("log_dropped_entries_total",
+		"Entries dropped by remote writers.", "writer", "reason")
+	metricRetries = newCounterVec("log_push_retries_total",
+		"Batch push retries of remote writers.", "writer")
+	metricBytesSent = newCounterVec("log_bytes_sent_total",
+		"Payload bytes successfully sent by remote writers.", "writer")
+	metricPushDuration = newHistogramVec("log_push_duration_seconds",
+		"Latency of a single batch push attempt.", defaultDurationBuckets, "writer")
+	metricQueueDepth = newGaugeFunc("log_queue_depth",
+		"Entries waiting in remote writer queues.", queueDepths, "writer")
+)
+
+// defaultDurationBuckets 推送耗时直方图的默认桶（秒）
+var defaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector 可输出为Prometheus文本格式的指标
+type collector interface {
+	metricName() string
+	writeText(w *bufio.Writer)
+}
+
+var (
+	metricsMu  sync.Mutex
+	collectors []collector
+)
+
+func register(c collector) {
+	metricsMu.Lock()
+	collectors = append(collectors, c)
+	metricsMu.Unlock()
+}
+
+// unregister 移除指标，用于随Init重建的日志派生指标
+func unregister(c collector) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	for i, existing := range collectors {
+		if existing == c {
+			collectors = append(collectors[:i], collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// WriteMetrics 以Prometheus文本格式（0.0.4）输出全部指标
+func WriteMetrics(w io.Writer) error {
+	metricsMu.Lock()
+	cs := append([]collector(nil), collectors...)
+	metricsMu.Unlock()
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].metricName() < cs[j].metricName() })
+
+	bw := bufio.NewWriter(w)
+	for _, c := range cs {
+		c.writeText(bw)
+	}
+	return bw.Flush()
+}
+
+// MetricsHandler 返回输出全部指标的HTTP处理器，可挂载到/metrics供Prometheus抓取
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WriteMetrics(w)
+	})
+}
+
+// counterVec 带标签的计数器
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+// counterSeries 一组标签值对应的计数
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// newCounterVec 创建并注册计数器
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	c := &counterVec{name: name, help: help, labels: labels, series: map[string]*counterSeries{}}
+	register(c)
+	return c
+}
+
+// add 按标签值累加，标签值数量须与定义一致
+func (c *counterVec) add(n float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += n
+	c.mu.Unlock()
+}
+
+// inc 按标签值加一
+func (c *counterVec) inc(values ...string) {
+	c.add(1, values...)
+}
+
+// get 返回标签值对应的当前计数
+func (c *counterVec) get(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[strings.Join(values, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *counterVec) metricName() string { return c.name }
+
+func (c *counterVec) writeText(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	keys := make([]string, 0, len(c.series))
+	for k := range c.series {
+		keys = append(keys, k)
+	}
+	for _, key := range sortKeys(keys) {
+		s := c.series[key]
+		writeSample(w, c.name, c.labels, s.values, "", "", s.value)
+	}
+}
+
+// histogramVec 带标签的直方图
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// histogramSeries 一组标签值对应的直方图，counts[i]为落入第i个桶（非累计）的次数
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// newHistogramVec 创建并注册直方图，buckets须升序
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	h := &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+	register(h)
+	return h
+}
+
+// observe 记录一次观测值
+func (h *histogramVec) observe(v float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *histogramVec) metricName() string { return h.name }
+
+func (h *histogramVec) writeText(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	for _, key := range sortKeys(keys) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.count))
+	}
+}
+
+// gaugeFunc 在输出时计算取值的仪表，collect返回标签值到取值的映射
+type gaugeFunc struct {
+	name    string
+	help    string
+	labels  []string
+	collect func() map[string]float64
+}
+
+// newGaugeFunc 创建并注册仪表，只支持单个标签
+func newGaugeFunc(name, help string, collect func() map[string]float64, label string) *gaugeFunc {
+	g := &gaugeFunc{name: name, help: help, labels: []string{label}, collect: collect}
+	register(g)
+	return g
+}
+
+func (g *gaugeFunc) metricName() string { return g.name }
+
+func (g *gaugeFunc) writeText(w *bufio.Writer) {
+	values := g.collect()
+	writeHeader(w, g.name, g.help, "gauge")
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	for _, k := range sortKeys(keys) {
+		writeSample(w, g.name, g.labels, []string{k}, "", "", values[k])
+	}
+}
+
+// sortKeys 排序后的键，使输出稳定
+func sortKeys(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+func writeHeader(w *bufio.Writer, name, help, typ string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeSample 输出一行样本，extraName非空时追加一个标签（直方图的le）
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, l, values[i])
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value))
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+	c := &counterVec{name: "test_total", help: "Test \\ counter.", labels: []string{"k"}, series: map[string]*counterSeries{}}
+	c.add(2, `a"b`)
+	c.inc("z\nq")
+	h := &histogramVec{name: "test_seconds", help: "h", labels: []string{"w"}, buckets: []float64{0.1, 1}, series: map[string]*histogramSeries{}}
+	h.observe(0.05, "x")
+	h.observe(0.5, "x")
+	h.observe(3, "x")
+
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	c.writeText(bw)
+	h.writeText(bw)
+	bw.Flush()
+
+	want := `# HELP test_total Test \\ counter.
+# TYPE test_total counter
+test_total{k="a\"b"} 2
+test_total{k="z\nq"} 1
+# HELP test_seconds h
+# TYPE test_seconds histogram
+test_seconds_bucket{w="x",le="0.1"} 1
+test_seconds_bucket{w="x",le="1"} 2
+test_seconds_bucket{w="x",le="+Inf"} 3
+test_seconds_sum{w="x"} 3.55
+test_seconds_count{w="x"} 3
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	srv, bodies := lokiTestServer(t)
+	ring := NewRingWriter(10)
+	Init(Config{
+		LokiURL: srv.URL,
+		Writers: map[string]Writer{"ring": ring},
+	})
+	defer Init(Config{})
+
+	entriesBefore := metricEntries.get("warn")
+	ringBefore := metricWriterEntries.get("ring")
+	bytesBefore := metricBytesSent.get("loki")
+
+	Warn("disk slow")
+	Warn("disk slow")
+	mu.Lock()
+	lw := loggers[0].(*LokiWriter)
+	mu.Unlock()
+	if err := lw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	body := <-bodies
+
+	if got := metricEntries.get("warn") - entriesBefore; got != 2 {
+		t.Errorf("log_entries_total = %v, want 2", got)
+	}
+	if got := metricWriterEntries.get("ring") - ringBefore; got != 2 {
+		t.Errorf("log_writer_entries_total = %v, want 2", got)
+	}
+	if got := metricBytesSent.get("loki") - bytesBefore; got != float64(len(body)) {
+		t.Errorf("log_bytes_sent_total = %v, want %d", got, len(body))
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type = %q", ct)
+	}
+	out, _ := io.ReadAll(rec.Body)
+	for _, want := range []string{
+		"# TYPE log_entries_total counter\n",
+		`log_writer_entries_total{writer="ring"}`,
+		`log_push_duration_seconds_count{writer="loki"}`,
+		`log_push_duration_seconds_bucket{writer="loki",le="+Inf"}`,
+		`log_queue_depth{writer="loki"} 0`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestBatcherDropMetrics(t *testing.T) {
+	before := metricDropped.get("test", "queue_full")
+	block := make(chan struct{})
+	b := newBatcher("test", BatchConfig{Size: 1, QueueSize: 1}, RetryConfig{}, func([]*LogEntry) error {
+		<-block
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		b.enqueue(&LogEntry{})
+	}
+	close(block)
+	b.close(context.Background())
+	// 后台协程取走一条、队列容纳一条，其余至少2条被丢弃
+	if got := metricDropped.get("test", "queue_full") - before; got < 2 {
+		t.Errorf("queue_full drops = %v, want >= 2", got)
+	}
+	if depth := queueDepths()["test"]; depth != 0 {
+		t.Errorf("closed batcher still reports depth %v", depth)
+	}
+}
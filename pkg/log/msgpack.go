@@ -0,0 +1,340 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 最小化的MessagePack编解码，供Fluent forward写入器使用，避免引入依赖
+
+// appendMsgpack 编码任意值；不认识的类型先经JSON转换为基本类型
+func appendMsgpack(b []byte, v interface{}) []byte {
+	switch tv := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if tv {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case string:
+		return appendMsgpackString(b, tv)
+	case []byte:
+		return appendMsgpackBin(b, tv)
+	case int:
+		return appendMsgpackInt(b, int64(tv))
+	case int8:
+		return appendMsgpackInt(b, int64(tv))
+	case int16:
+		return appendMsgpackInt(b, int64(tv))
+	case int32:
+		return appendMsgpackInt(b, int64(tv))
+	case int64:
+		return appendMsgpackInt(b, tv)
+	case uint:
+		return appendMsgpackUint(b, uint64(tv))
+	case uint8:
+		return appendMsgpackUint(b, uint64(tv))
+	case uint16:
+		return appendMsgpackUint(b, uint64(tv))
+	case uint32:
+		return appendMsgpackUint(b, uint64(tv))
+	case uint64:
+		return appendMsgpackUint(b, tv)
+	case float32:
+		return appendMsgpackFloat(b, float64(tv))
+	case float64:
+		return appendMsgpackFloat(b, tv)
+	case json.Number:
+		if i, err := tv.Int64(); err == nil {
+			return appendMsgpackInt(b, i)
+		}
+		f, _ := tv.Float64()
+		return appendMsgpackFloat(b, f)
+	case time.Time:
+		return appendMsgpackString(b, tv.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendMsgpackString(b, tv.String())
+	case error:
+		return appendMsgpackString(b, tv.Error())
+	case fmt.Stringer:
+		return appendMsgpackString(b, tv.String())
+	case []interface{}:
+		b = appendMsgpackArrayHeader(b, len(tv))
+		for _, e := range tv {
+			b = appendMsgpack(b, e)
+		}
+		return b
+	case map[string]interface{}:
+		b = appendMsgpackMapHeader(b, len(tv))
+		for _, k := range sortedKeys(tv) {
+			b = appendMsgpackString(b, k)
+			b = appendMsgpack(b, tv[k])
+		}
+		return b
+	case map[string]string:
+		b = appendMsgpackMapHeader(b, len(tv))
+		for _, k := range sortedStringKeys(tv) {
+			b = appendMsgpackString(b, k)
+			b = appendMsgpackString(b, tv[k])
+		}
+		return b
+	}
+
+	// 其他类型（结构体、切片、指针等）经JSON转为基本类型
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return append(b, 0xc0)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return appendMsgpackString(b, fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return appendMsgpackString(b, string(data))
+	}
+	return appendMsgpack(b, generic)
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackBin(b []byte, v []byte) []byte {
+	n := len(v)
+	switch {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xc5)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xc6)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, v...)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	if v >= 0 {
+		return appendMsgpackUint(b, uint64(v))
+	}
+	switch {
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		b = append(b, 0xd1)
+		return binary.BigEndian.AppendUint16(b, uint16(v))
+	case v >= math.MinInt32:
+		b = append(b, 0xd2)
+		return binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	b = append(b, 0xd3)
+	return binary.BigEndian.AppendUint64(b, uint64(v))
+}
+
+func appendMsgpackUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		b = append(b, 0xcd)
+		return binary.BigEndian.AppendUint16(b, uint16(v))
+	case v <= math.MaxUint32:
+		b = append(b, 0xce)
+		return binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	b = append(b, 0xcf)
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func appendMsgpackFloat(b []byte, v float64) []byte {
+	b = append(b, 0xcb)
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xdc)
+		return binary.BigEndian.AppendUint16(b, uint16(n))
+	}
+	b = append(b, 0xdd)
+	return binary.BigEndian.AppendUint32(b, uint32(n))
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xde)
+		return binary.BigEndian.AppendUint16(b, uint16(n))
+	}
+	b = append(b, 0xdf)
+	return binary.BigEndian.AppendUint32(b, uint32(n))
+}
+
+// readMsgpack 解码一个值；整数统一为int64（超出范围的无符号数为uint64），map键须为字符串，扩展类型返回原始字节
+func readMsgpack(r *bufio.Reader) (interface{}, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return readMsgpackStr(r, int(c&0x1f))
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, int(c&0x0f))
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgpackLen(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, n)
+	case 0xca:
+		v, err := readMsgpackUint(r, 4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := readMsgpackUint(r, 8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := readMsgpackUint(r, 1<<(c-0xcc))
+		if v <= math.MaxInt64 {
+			return int64(v), err
+		}
+		return v, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := readMsgpackUint(r, size)
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		// fixext：类型字节+1/2/4/8/16字节数据
+		return readMsgpackBytes(r, 1+1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgpackLen(r, 1<<(c-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackBytes(r, n+1)
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgpackLen(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackStr(r, n)
+	case 0xdc, 0xdd:
+		n, err := readMsgpackLen(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n)
+	case 0xde, 0xdf:
+		n, err := readMsgpackLen(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n)
+	}
+	return nil, fmt.Errorf("unsupported msgpack type 0x%02x", c)
+}
+
+func readMsgpackUint(r *bufio.Reader, size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func readMsgpackLen(r *bufio.Reader, size int) (int, error) {
+	n, err := readMsgpackUint(r, size)
+	return int(n), err
+}
+
+func readMsgpackBytes(r *bufio.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+func readMsgpackStr(r *bufio.Reader, n int) (string, error) {
+	buf, err := readMsgpackBytes(r, n)
+	return string(buf), err
+}
+
+func readMsgpackArray(r *bufio.Reader, n int) ([]interface{}, error) {
+	out := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n int) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack map key must be string, got %T", k)
+		}
+		v, err := readMsgpack(r)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
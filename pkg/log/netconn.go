@@ -0,0 +1,103 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// reconnectConn 自动重连的网络连接
+// 首次使用时建立连接，读写失败后关闭并重新建立连接重试一次；
+// 流式连接在使用前检查是否已被对端关闭，避免写入已关闭的连接后数据静默丢失
+type reconnectConn struct {
+	networks []string // 依次尝试的网络类型，如unix套接字可能是unixgram或unix
+	addr     string
+	timeout  time.Duration
+	conn     net.Conn
+	network  string // 当前连接实际使用的网络类型
+	mu       sync.Mutex
+}
+
+func newReconnectConn(addr string, timeout time.Duration, networks ...string) *reconnectConn {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &reconnectConn{networks: networks, addr: addr, timeout: timeout}
+}
+
+// dial 依次尝试各网络类型建立连接
+func (rc *reconnectConn) dial() error {
+	var lastErr error
+	for _, network := range rc.networks {
+		conn, err := net.DialTimeout(network, rc.addr, rc.timeout)
+		if err == nil {
+			rc.conn, rc.network = conn, network
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("failed to connect to %s: %w", rc.addr, lastErr)
+}
+
+// do 在连接上执行fn，失败时重连后再试一次，fn中可读取rc.network
+func (rc *reconnectConn) do(fn func(conn net.Conn) error) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.conn != nil && !rc.alive() {
+		rc.conn.Close()
+		rc.conn = nil
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if rc.conn == nil {
+			if err = rc.dial(); err != nil {
+				continue
+			}
+		}
+		_ = rc.conn.SetDeadline(time.Now().Add(rc.timeout))
+		if err = fn(rc.conn); err == nil {
+			return nil
+		}
+		rc.conn.Close()
+		rc.conn = nil
+	}
+	return err
+}
+
+// alive 检查流式连接是否仍然可用，读到EOF或错误说明对端已关闭
+// 只用于发送方向的协议，对端不会主动发送数据
+func (rc *reconnectConn) alive() bool {
+	switch rc.network {
+	case "tcp", "unix":
+	default:
+		return true
+	}
+	_ = rc.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var b [1]byte
+	_, err := rc.conn.Read(b[:])
+	var ne net.Error
+	return err == nil || errors.As(err, &ne) && ne.Timeout()
+}
+
+// write 写入数据，失败时自动重连
+func (rc *reconnectConn) write(b []byte) error {
+	return rc.do(func(conn net.Conn) error {
+		_, err := conn.Write(b)
+		return err
+	})
+}
+
+// close 关闭连接
+func (rc *reconnectConn) close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.conn == nil {
+		return nil
+	}
+	err := rc.conn.Close()
+	rc.conn = nil
+	return err
+}
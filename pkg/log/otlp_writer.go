@@ -0,0 +1,449 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP编码方式
+const (
+	OTLPEncodingProtobuf = "protobuf"
+	OTLPEncodingJSON     = "json"
+)
+
+// otlpSeverity 日志级别到OTel SeverityNumber
+var otlpSeverity = map[string]int{
+	"debug": 5,
+	"info":  9,
+	"warn":  13,
+	"error": 17,
+}
+
+// OTLPWriterConfig OpenTelemetry OTLP/HTTP日志导出配置
+// Endpoint: Collector地址，如"http://otel-collector:4318"，未以/v1/logs结尾时自动追加
+// Encoding: "protobuf"（默认）或"json"
+// Labels: 附加的资源属性，与每条日志的Labels合并；存在service标签时补充service.name
+// TraceIDField/SpanIDField: 作为追踪上下文的字段名，默认"trace_id"与"span_id"
+type OTLPWriterConfig struct {
+	Endpoint     string            // Collector地址
+	Encoding     string            // 编码方式
+	Headers      map[string]string // 附加请求头，如认证信息
+	Labels       map[string]string // 附加资源属性
+	ScopeName    string            // InstrumentationScope名称
+	TraceIDField string            // trace id字段名
+	SpanIDField  string            // span id字段名
+	Batch        BatchConfig       // 批量配置
+	Retry        RetryConfig       // 重试配置
+	Timeout      time.Duration     // 单次请求超时，默认10秒
+}
+
+// OTLPWriter OTLP/HTTP日志导出器，实现Writer接口
+// Level映射为SeverityNumber，Labels为资源属性，Fields为日志属性
+
+type OTLPWriter struct {
+	cfg        OTLPWriterConfig
+	url        string
+	httpClient *http.Client
+	batcher    *batcher
+}
+
+// NewOTLPWriter 创建OTLP写入器
+func NewOTLPWriter(c OTLPWriterConfig) (*OTLPWriter, error) {
+	if c.Endpoint == "" {
+		return nil, fmt.Errorf("otlp endpoint is required")
+	}
+	if c.Encoding == "" {
+		c.Encoding = OTLPEncodingProtobuf
+	}
+	if c.Encoding != OTLPEncodingProtobuf && c.Encoding != OTLPEncodingJSON {
+		return nil, fmt.Errorf("unknown otlp encoding %q", c.Encoding)
+	}
+	if c.ScopeName == "" {
+		c.ScopeName = "github.com/TEENet-io/logdashboard/pkg/log"
+	}
+	if c.TraceIDField == "" {
+		c.TraceIDField = "trace_id"
+	}
+	if c.SpanIDField == "" {
+		c.SpanIDField = "span_id"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+
+	u := strings.TrimSuffix(c.Endpoint, "/")
+	if !strings.HasSuffix(u, "/v1/logs") {
+		u += "/v1/logs"
+	}
+	ow := &OTLPWriter{cfg: c, url: u, httpClient: &http.Client{Timeout: c.Timeout}}
+	ow.batcher = newBatcher("otlp", c.Batch, c.Retry, ow.push)
+	return ow, nil
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台批量导出
+func (ow *OTLPWriter) Write(entry *LogEntry) error {
+	return ow.batcher.enqueue(entry)
+}
+
+// Flush 导出队列中已有的日志
+func (ow *OTLPWriter) Flush(ctx context.Context) error {
+	return ow.batcher.flush(ctx)
+}
+
+// Close 导出剩余日志并停止后台协程
+func (ow *OTLPWriter) Close() error {
+	return ow.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (ow *OTLPWriter) closeContext(ctx context.Context) error {
+	return ow.batcher.close(ctx)
+}
+
+// otlpRecord 与编码方式无关的日志记录
+type otlpRecord struct {
+	timeNano uint64
+	severity int
+	level    string
+	body     string
+	attrs    map[string]interface{}
+	traceID  []byte
+	spanID   []byte
+}
+
+// otlpResource 一组共享资源属性的日志记录
+type otlpResource struct {
+	attrs   map[string]string
+	records []otlpRecord
+}
+
+// group 按标签集合将日志分为资源
+func (ow *OTLPWriter) group(batch []*LogEntry) []*otlpResource {
+	byKey := map[string]*otlpResource{}
+	var order []*otlpResource
+	for _, entry := range batch {
+		attrs := map[string]string{}
+		for k, v := range ow.cfg.Labels {
+			attrs[k] = v
+		}
+		for k, v := range entry.Labels {
+			attrs[k] = v
+		}
+		if svc, ok := attrs["service"]; ok {
+			if _, exists := attrs["service.name"]; !exists {
+				attrs["service.name"] = svc
+			}
+		}
+		key := labelsKey(attrs)
+		res, ok := byKey[key]
+		if !ok {
+			res = &otlpResource{attrs: attrs}
+			byKey[key] = res
+			order = append(order, res)
+		}
+		res.records = append(res.records, ow.record(entry))
+	}
+	return order
+}
+
+// record 转换单条日志，合法的trace/span id字段作为追踪上下文，不再重复为属性
+func (ow *OTLPWriter) record(entry *LogEntry) otlpRecord {
+	r := otlpRecord{
+		timeNano: uint64(time.Unix(entry.Time, 0).UnixNano()),
+		severity: otlpSeverity[entry.Level],
+		level:    entry.Level,
+		body:     entry.Message,
+		attrs:    map[string]interface{}{},
+	}
+	for k, v := range entry.Fields {
+		r.attrs[k] = v
+	}
+	if entry.Caller != "" {
+		r.attrs["code.filepath"] = entry.Caller
+	}
+	if id, ok := hexField(r.attrs, ow.cfg.TraceIDField, 16); ok {
+		r.traceID = id
+		delete(r.attrs, ow.cfg.TraceIDField)
+	}
+	if id, ok := hexField(r.attrs, ow.cfg.SpanIDField, 8); ok {
+		r.spanID = id
+		delete(r.attrs, ow.cfg.SpanIDField)
+	}
+	return r
+}
+
+// hexField 读取指定长度的十六进制id字段
+func hexField(fields map[string]interface{}, key string, size int) ([]byte, bool) {
+	s, ok := fields[key].(string)
+	if !ok || len(s) != size*2 {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// push 编码并导出一批日志
+func (ow *OTLPWriter) push(batch []*LogEntry) error {
+	resources := ow.group(batch)
+
+	var body []byte
+	var contentType string
+	if ow.cfg.Encoding == OTLPEncodingJSON {
+		b, err := json.Marshal(ow.encodeJSON(resources))
+		if err != nil {
+			return permanent(fmt.Errorf("failed to marshal otlp request: %w", err))
+		}
+		body, contentType = b, "application/json"
+	} else {
+		body, contentType = ow.encodeProto(resources), "application/x-protobuf"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ow.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range ow.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := ow.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to OTLP endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		err := fmt.Errorf("OTLP endpoint returned status code %d", resp.StatusCode)
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	metricBytesSent.add(float64(len(body)), "otlp")
+	return nil
+}
+
+// encodeProto 编码ExportLogsServiceRequest
+func (ow *OTLPWriter) encodeProto(resources []*otlpResource) []byte {
+	var scope []byte
+	scope = appendProtoString(scope, 1, ow.cfg.ScopeName)
+
+	var req []byte
+	for _, res := range resources {
+		var resource []byte
+		for _, k := range sortedStringKeys(res.attrs) {
+			resource = appendProtoBytes(resource, 1, protoKeyValue(k, res.attrs[k]))
+		}
+
+		var scopeLogs []byte
+		scopeLogs = appendProtoBytes(scopeLogs, 1, scope)
+		for _, r := range res.records {
+			scopeLogs = appendProtoBytes(scopeLogs, 2, protoLogRecord(r))
+		}
+
+		var rl []byte
+		rl = appendProtoBytes(rl, 1, resource)
+		rl = appendProtoBytes(rl, 2, scopeLogs)
+		req = appendProtoBytes(req, 1, rl)
+	}
+	return req
+}
+
+// protoLogRecord 编码LogRecord
+func protoLogRecord(r otlpRecord) []byte {
+	var b []byte
+	b = appendProtoFixed64(b, 1, r.timeNano)
+	b = appendProtoVarint(b, 2, uint64(r.severity))
+	b = appendProtoString(b, 3, r.level)
+	b = appendProtoBytes(b, 5, protoAnyValue(r.body))
+	for _, k := range sortedKeys(r.attrs) {
+		b = appendProtoBytes(b, 6, protoKeyValue(k, r.attrs[k]))
+	}
+	if r.traceID != nil {
+		b = appendProtoBytes(b, 9, r.traceID)
+	}
+	if r.spanID != nil {
+		b = appendProtoBytes(b, 10, r.spanID)
+	}
+	b = appendProtoFixed64(b, 11, r.timeNano)
+	return b
+}
+
+func protoKeyValue(k string, v interface{}) []byte {
+	var b []byte
+	b = appendProtoString(b, 1, k)
+	return appendProtoBytes(b, 2, protoAnyValue(v))
+}
+
+// protoAnyValue 编码AnyValue：string=1, bool=2, int=3, double=4, array=5, kvlist=6
+func protoAnyValue(v interface{}) []byte {
+	var b []byte
+	switch tv := otlpNormalize(v).(type) {
+	case string:
+		b = appendProtoString(b, 1, tv)
+	case bool:
+		x := uint64(0)
+		if tv {
+			x = 1
+		}
+		b = appendProtoVarint(b, 2, x)
+	case int64:
+		b = appendProtoVarint(b, 3, uint64(tv))
+	case float64:
+		b = appendProtoDouble(b, 4, tv)
+	case []interface{}:
+		var arr []byte
+		for _, e := range tv {
+			arr = appendProtoBytes(arr, 1, protoAnyValue(e))
+		}
+		b = appendProtoBytes(b, 5, arr)
+	case map[string]interface{}:
+		var kvs []byte
+		for _, k := range sortedKeys(tv) {
+			kvs = appendProtoBytes(kvs, 1, protoKeyValue(k, tv[k]))
+		}
+		b = appendProtoBytes(b, 6, kvs)
+	}
+	return b
+}
+
+// encodeJSON 按OTLP/JSON编码，int64与时间戳以字符串表示
+func (ow *OTLPWriter) encodeJSON(resources []*otlpResource) map[string]interface{} {
+	var rls []interface{}
+	for _, res := range resources {
+		var attrs []interface{}
+		for _, k := range sortedStringKeys(res.attrs) {
+			attrs = append(attrs, jsonKeyValue(k, res.attrs[k]))
+		}
+		var records []interface{}
+		for _, r := range res.records {
+			rec := map[string]interface{}{
+				"timeUnixNano":         strconv.FormatUint(r.timeNano, 10),
+				"observedTimeUnixNano": strconv.FormatUint(r.timeNano, 10),
+				"severityNumber":       r.severity,
+				"severityText":         r.level,
+				"body":                 jsonAnyValue(r.body),
+			}
+			var recAttrs []interface{}
+			for _, k := range sortedKeys(r.attrs) {
+				recAttrs = append(recAttrs, jsonKeyValue(k, r.attrs[k]))
+			}
+			if len(recAttrs) > 0 {
+				rec["attributes"] = recAttrs
+			}
+			if r.traceID != nil {
+				rec["traceId"] = hex.EncodeToString(r.traceID)
+			}
+			if r.spanID != nil {
+				rec["spanId"] = hex.EncodeToString(r.spanID)
+			}
+			records = append(records, rec)
+		}
+		rls = append(rls, map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": attrs},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]interface{}{"name": ow.cfg.ScopeName},
+				"logRecords": records,
+			}},
+		})
+	}
+	return map[string]interface{}{"resourceLogs": rls}
+}
+
+func jsonKeyValue(k string, v interface{}) map[string]interface{} {
+	return map[string]interface{}{"key": k, "value": jsonAnyValue(v)}
+}
+
+func jsonAnyValue(v interface{}) map[string]interface{} {
+	switch tv := otlpNormalize(v).(type) {
+	case string:
+		return map[string]interface{}{"stringValue": tv}
+	case bool:
+		return map[string]interface{}{"boolValue": tv}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(tv, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": tv}
+	case []interface{}:
+		values := make([]interface{}, 0, len(tv))
+		for _, e := range tv {
+			values = append(values, jsonAnyValue(e))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		values := make([]interface{}, 0, len(tv))
+		for _, k := range sortedKeys(tv) {
+			values = append(values, jsonKeyValue(k, tv[k]))
+		}
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": values}}
+	}
+	return map[string]interface{}{}
+}
+
+// otlpNormalize 将字段值归一为string/bool/int64/float64/[]interface{}/map[string]interface{}
+func otlpNormalize(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int64, float64, []interface{}, map[string]interface{}:
+		return tv
+	case json.Number:
+		if i, err := tv.Int64(); err == nil {
+			return i
+		}
+		f, _ := tv.Float64()
+		return f
+	case error:
+		return tv.Error()
+	case fmt.Stringer:
+		return tv.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	}
+
+	// 其他类型（结构体、切片等）按JSON往返转换
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var out interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return string(b)
+	}
+	return otlpNormalize(out)
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
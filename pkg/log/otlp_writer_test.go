@@ -0,0 +1,118 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func otlpTestServer(t *testing.T) (*httptest.Server, chan []byte) {
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		b, _ := io.ReadAll(r.Body)
+		bodies <- b
+	}))
+	return srv, bodies
+}
+
+func otlpTestEntry() *LogEntry {
+	return &LogEntry{
+		Level:   "warn",
+		Message: "slow query",
+		Labels:  map[string]string{"service": "api"},
+		Fields: map[string]interface{}{
+			"trace_id": "0102030405060708090a0b0c0d0e0f10",
+			"span_id":  "0102030405060708",
+			"ms":       42,
+		},
+		Time: 1700000000,
+	}
+}
+
+func TestOTLPWriterProtobuf(t *testing.T) {
+	srv, bodies := otlpTestServer(t)
+	defer srv.Close()
+
+	ow, err := NewOTLPWriter(OTLPWriterConfig{Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ow.Write(otlpTestEntry())
+	if err := ow.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = ow.Close()
+
+	req := protoFields(<-bodies)
+	rl := protoFields(req[1][0].([]byte))
+	resource := protoFields(rl[1][0].([]byte))
+	if len(resource[1]) != 2 { // service + service.name
+		t.Fatalf("resource attributes = %d, want 2", len(resource[1]))
+	}
+	scopeLogs := protoFields(rl[2][0].([]byte))
+	rec := protoFields(scopeLogs[2][0].([]byte))
+	if rec[2][0].(uint64) != 13 || string(rec[3][0].([]byte)) != "warn" {
+		t.Errorf("unexpected severity %v %s", rec[2], rec[3])
+	}
+	if len(rec[9][0].([]byte)) != 16 || len(rec[10][0].([]byte)) != 8 {
+		t.Errorf("trace context missing: %v %v", rec[9], rec[10])
+	}
+	if len(rec[6]) != 1 { // 只剩ms，trace_id/span_id不再重复为属性
+		t.Errorf("attributes = %d, want 1", len(rec[6]))
+	}
+	attr := protoFields(rec[6][0].([]byte))
+	val := protoFields(attr[2][0].([]byte))
+	if val[3][0].(uint64) != 42 {
+		t.Errorf("int attribute = %v", val)
+	}
+}
+
+func TestOTLPWriterJSON(t *testing.T) {
+	srv, bodies := otlpTestServer(t)
+	defer srv.Close()
+
+	ow, err := NewOTLPWriter(OTLPWriterConfig{Endpoint: srv.URL + "/v1/logs", Encoding: OTLPEncodingJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ow.Write(otlpTestEntry())
+	_ = ow.Close()
+
+	var req struct {
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string `json:"timeUnixNano"`
+					SeverityNumber int    `json:"severityNumber"`
+					TraceID        string `json:"traceId"`
+					Body           struct {
+						StringValue string `json:"stringValue"`
+					} `json:"body"`
+					Attributes []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatal(err)
+	}
+	rec := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if rec.TimeUnixNano != "1700000000000000000" || rec.SeverityNumber != 13 || rec.Body.StringValue != "slow query" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if rec.TraceID != "0102030405060708090a0b0c0d0e0f10" {
+		t.Errorf("traceId = %q", rec.TraceID)
+	}
+	if len(rec.Attributes) != 1 || rec.Attributes[0].Value["intValue"] != "42" {
+		t.Errorf("attributes = %+v", rec.Attributes)
+	}
+}
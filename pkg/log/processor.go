@@ -0,0 +1,88 @@
+package log
+
+// Processor 日志处理器，在日志分发到写入器之前对其增强、转换或丢弃
+// 可以直接修改并返回传入的entry，返回false时丢弃该日志
+// 全局处理器在构建日志后按顺序执行；写入器处理器作用于日志的副本（见LogEntry.Clone），互不影响
+// 处理器在持有日志模块内部锁时执行，不能调用Debug、Info、Flush等本包的函数，否则会死锁，也不应长时间阻塞
+type Processor interface {
+	Process(entry *LogEntry) (*LogEntry, bool)
+}
+
+// ProcessorFunc 函数形式的处理器
+type ProcessorFunc func(entry *LogEntry) (*LogEntry, bool)
+
+// Process 实现Processor接口
+func (f ProcessorFunc) Process(entry *LogEntry) (*LogEntry, bool) {
+	return f(entry)
+}
+
+// runProcessors 依次执行处理器链，任一处理器丢弃时返回false
+func runProcessors(chain []Processor, entry *LogEntry) (*LogEntry, bool) {
+	for _, p := range chain {
+		var ok bool
+		if entry, ok = p.Process(entry); !ok || entry == nil {
+			return nil, false
+		}
+	}
+	return entry, true
+}
+
+// AddFields 添加静态字段，已存在的同名字段不被覆盖
+func AddFields(fields map[string]interface{}) Processor {
+	return ProcessorFunc(func(entry *LogEntry) (*LogEntry, bool) {
+		if entry.Fields == nil {
+			entry.Fields = map[string]interface{}{}
+		}
+		for k, v := range fields {
+			if _, exists := entry.Fields[k]; !exists {
+				entry.Fields[k] = v
+			}
+		}
+		return entry, true
+	})
+}
+
+// RenameKeys 按旧名到新名的映射重命名字段与标签
+func RenameKeys(mapping map[string]string) Processor {
+	return ProcessorFunc(func(entry *LogEntry) (*LogEntry, bool) {
+		for from, to := range mapping {
+			if v, ok := entry.Fields[from]; ok {
+				delete(entry.Fields, from)
+				entry.Fields[to] = v
+			}
+			if v, ok := entry.Labels[from]; ok {
+				delete(entry.Labels, from)
+				entry.Labels[to] = v
+			}
+		}
+		return entry, true
+	})
+}
+
+// DropFields 删除指定字段
+func DropFields(keys ...string) Processor {
+	return ProcessorFunc(func(entry *LogEntry) (*LogEntry, bool) {
+		for _, k := range keys {
+			delete(entry.Fields, k)
+		}
+		return entry, true
+	})
+}
+
+// PromoteToLabels 将指定字段移为标签，非字符串值转为字符串
+func PromoteToLabels(keys ...string) Processor {
+	return ProcessorFunc(func(entry *LogEntry) (*LogEntry, bool) {
+		for _, k := range keys {
+			v, ok := entry.Fields[k]
+			if !ok {
+				continue
+			}
+			if entry.Labels == nil {
+				entry.Labels = map[string]string{}
+			}
+			entry.Labels[k] = fieldString(v)
+			delete(entry.Fields, k)
+		}
+		return entry, true
+	})
+}
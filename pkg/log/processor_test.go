@@ -0,0 +1,81 @@
+package log
+
+import (
+	"testing"
+)
+
+func TestBuiltinProcessors(t *testing.T) {
+	entry := &LogEntry{
+		Level:   "info",
+		Message: "m",
+		Labels:  map[string]string{"svc": "api"},
+		Fields:  map[string]interface{}{"uid": 42, "password": "x", "region": "cn"},
+	}
+	chain := []Processor{
+		AddFields(map[string]interface{}{"version": "1.2.0", "region": "us"}),
+		RenameKeys(map[string]string{"uid": "user_id", "svc": "service"}),
+		DropFields("password"),
+		PromoteToLabels("region"),
+	}
+	out, ok := runProcessors(chain, entry)
+	if !ok {
+		t.Fatal("entry dropped")
+	}
+	if out.Fields["version"] != "1.2.0" || out.Fields["user_id"] != 42 || len(out.Fields) != 2 {
+		t.Errorf("fields = %v", out.Fields)
+	}
+	if out.Labels["service"] != "api" || out.Labels["region"] != "cn" || len(out.Labels) != 2 {
+		t.Errorf("labels = %v", out.Labels)
+	}
+}
+
+func TestProcessorChainsInInit(t *testing.T) {
+	all, redacted := NewRingWriter(10), NewRingWriter(10)
+	dropDebug := ProcessorFunc(func(e *LogEntry) (*LogEntry, bool) { return e, e.Level != "debug" })
+	Init(Config{
+		Level:      "debug",
+		Writers:    map[string]Writer{"all": all, "redacted": redacted},
+		Processors: []Processor{dropDebug, AddFields(map[string]interface{}{"host": "h1"})},
+		WriterProcessors: map[string][]Processor{
+			"redacted": {DropFields("token")},
+		},
+	})
+	defer Init(Config{})
+
+	Debug("dropped")
+	Info("login", NewField("token", "secret"))
+
+	if all.Len() != 1 || redacted.Len() != 1 {
+		t.Fatalf("all=%d redacted=%d, want 1 each", all.Len(), redacted.Len())
+	}
+	a, r := all.Entries(RingFilter{})[0], redacted.Entries(RingFilter{})[0]
+	if a.Fields["token"] != "secret" || a.Fields["host"] != "h1" {
+		t.Errorf("writer processor must not affect other writers: %v", a.Fields)
+	}
+	if _, ok := r.Fields["token"]; ok || r.Fields["host"] != "h1" {
+		t.Errorf("redacted fields = %v", r.Fields)
+	}
+}
+
+func TestWriterProcessorNestedValues(t *testing.T) {
+	all, scrubbed := NewRingWriter(10), NewRingWriter(10)
+	scrub := ProcessorFunc(func(e *LogEntry) (*LogEntry, bool) {
+		e.Fields["user"].(map[string]interface{})["token"] = "***"
+		e.Fields["tags"].([]string)[0] = "***"
+		return e, true
+	})
+	Init(Config{
+		Writers:          map[string]Writer{"all": all, "scrubbed": scrubbed},
+		WriterProcessors: map[string][]Processor{"scrubbed": {scrub}},
+	})
+	defer Init(Config{})
+
+	Info("login", NewField("user", map[string]interface{}{"token": "secret"}), NewField("tags", []string{"a"}))
+	a, s := all.Entries(RingFilter{})[0], scrubbed.Entries(RingFilter{})[0]
+	if a.Fields["user"].(map[string]interface{})["token"] != "secret" || a.Fields["tags"].([]string)[0] != "a" {
+		t.Errorf("nested values changed for other writers: %v", a.Fields)
+	}
+	if s.Fields["user"].(map[string]interface{})["token"] != "***" {
+		t.Errorf("scrubbed fields = %v", s.Fields)
+	}
+}
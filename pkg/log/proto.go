@@ -0,0 +1,56 @@
+package log
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// 最小化的protobuf编码工具，供SLS、OTLP等写入器使用，避免引入依赖
+
+// protobuf线路类型
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendProtoTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendProtoVarint 写入varint字段（int32/int64/uint32/uint64/bool/enum）
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = appendProtoTag(b, field, protoVarint)
+	return appendVarint(b, v)
+}
+
+// appendProtoBytes 写入length-delimited字段（string/bytes/嵌套消息）
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendProtoString(b []byte, field int, v string) []byte {
+	b = appendProtoTag(b, field, protoBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendProtoFixed64(b []byte, field int, v uint64) []byte {
+	b = appendProtoTag(b, field, protoFixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendProtoDouble(b []byte, field int, v float64) []byte {
+	return appendProtoFixed64(b, field, math.Float64bits(v))
+}
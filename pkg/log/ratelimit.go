@@ -0,0 +1,196 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	metricRateLimitedEntries = newCounterVec("log_rate_limited_entries_total",
+		"Entries dropped by per-writer rate limits.", "writer")
+	metricRateLimitedBytes = newCounterVec("log_rate_limited_bytes_total",
+		"Estimated bytes of entries dropped by per-writer rate limits.", "writer")
+)
+
+// RateLimitConfig 写入器限流配置，令牌桶算法，超出的日志被丢弃
+// Lines/Bytes: 每秒允许的条数与字节数，<=0表示不限制；字节数按Message、标签与字段的长度估算
+// BurstLines/BurstBytes: 桶容量，默认等于每秒速率
+// ReportInterval: 有丢弃时输出"dropped N entries"汇总日志的间隔，默认10秒，负数不输出
+type RateLimitConfig struct {
+	Lines          float64       // 每秒条数
+	Bytes          float64       // 每秒字节数
+	BurstLines     float64       // 条数桶容量
+	BurstBytes     float64       // 字节数桶容量
+	ReportInterval time.Duration // 汇总间隔
+}
+
+// RateLimiter 限流处理器，实现Processor接口
+// 通过Config.RateLimits配置时放在对应写入器处理器链的最后
+type RateLimiter struct {
+	name   string
+	now    func() time.Time
+	lines  tokenBucket
+	bytes  tokenBucket
+	report time.Duration
+
+	mu           sync.Mutex
+	dropped      int
+	droppedBytes int
+	last         *LogEntry
+	scheduled    bool
+	emit         func(*LogEntry)
+}
+
+// tokenBucket 令牌桶，rate<=0时不限制
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	at     time.Time
+}
+
+func newTokenBucket(rate, burst float64) tokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// refill 按经过的时间补充令牌
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.at.IsZero() {
+		b.tokens += now.Sub(b.at).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.at = now
+}
+
+// allows 是否有足够的令牌，超过桶容量的消耗按桶满计算，避免大日志永远无法通过
+func (b *tokenBucket) allows(n float64) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	if n > b.burst {
+		n = b.burst
+	}
+	return b.tokens >= n
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate <= 0 {
+		return
+	}
+	if n > b.burst {
+		n = b.burst
+	}
+	b.tokens -= n
+}
+
+// NewRateLimiter 创建限流处理器，name用于指标与汇总日志中的writer字段
+func NewRateLimiter(name string, c RateLimitConfig) *RateLimiter {
+	if c.ReportInterval == 0 {
+		c.ReportInterval = 10 * time.Second
+	}
+	return &RateLimiter{
+		name:   name,
+		now:    time.Now,
+		lines:  newTokenBucket(c.Lines, c.BurstLines),
+		bytes:  newTokenBucket(c.Bytes, c.BurstBytes),
+		report: c.ReportInterval,
+	}
+}
+
+// setEmit 设置汇总日志的输出函数，由Init注入
+func (r *RateLimiter) setEmit(emit func(*LogEntry)) {
+	r.mu.Lock()
+	r.emit = emit
+	r.mu.Unlock()
+}
+
+// Process 实现Processor接口
+func (r *RateLimiter) Process(entry *LogEntry) (*LogEntry, bool) {
+	size := 0
+	if r.bytes.rate > 0 {
+		size = entrySize(entry)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.lines.refill(now)
+	r.bytes.refill(now)
+	if r.lines.allows(1) && r.bytes.allows(float64(size)) {
+		r.lines.take(1)
+		r.bytes.take(float64(size))
+		return entry, true
+	}
+
+	if size == 0 {
+		size = entrySize(entry)
+	}
+	r.dropped++
+	r.droppedBytes += size
+	r.last = entry
+	metricRateLimitedEntries.inc(r.name)
+	metricRateLimitedBytes.add(float64(size), r.name)
+	if r.report > 0 && !r.scheduled {
+		r.scheduled = true
+		time.AfterFunc(r.report, r.flushReport)
+	}
+	return nil, false
+}
+
+// flushReport 输出上一周期的丢弃汇总
+func (r *RateLimiter) flushReport() {
+	r.mu.Lock()
+	n, bytes, last, emit := r.dropped, r.droppedBytes, r.last, r.emit
+	r.dropped, r.droppedBytes, r.last, r.scheduled = 0, 0, nil, false
+	r.mu.Unlock()
+
+	if n == 0 || emit == nil {
+		return
+	}
+	emit(r.droppedEntry(n, bytes, last))
+}
+
+// flushPending 立即输出丢弃汇总，Flush与Shutdown时调用
+func (r *RateLimiter) flushPending() {
+	r.flushReport()
+}
+
+// droppedEntry 构造丢弃汇总日志，标签取自最后一条被丢弃的日志以进入相同的流
+func (r *RateLimiter) droppedEntry(n, bytes int, last *LogEntry) *LogEntry {
+	labels := make(map[string]string, len(last.Labels))
+	for k, v := range last.Labels {
+		labels[k] = v
+	}
+	if _, ok := labels["level"]; ok {
+		labels["level"] = "warn"
+	}
+	return &LogEntry{
+		Level:   "warn",
+		Message: fmt.Sprintf("dropped %d entries", n),
+		Labels:  labels,
+		Fields: map[string]interface{}{
+			"writer":          r.name,
+			"dropped_entries": n,
+			"dropped_bytes":   bytes,
+		},
+		Time: r.now().Unix(),
+	}
+}
+
+// entrySize 估算日志的字节数
+func entrySize(entry *LogEntry) int {
+	n := len(entry.Message) + len(entry.Level) + len(entry.Caller)
+	for k, v := range entry.Labels {
+		n += len(k) + len(v)
+	}
+	for k, v := range entry.Fields {
+		n += len(k) + len(fieldString(v))
+	}
+	return n
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterLines(t *testing.T) {
+	r := NewRateLimiter("loki", RateLimitConfig{Lines: 10, BurstLines: 5, ReportInterval: -1})
+	now := time.Unix(1700000000, 0)
+	r.now = func() time.Time { return now }
+	before := metricRateLimitedEntries.get("loki")
+
+	passed := 0
+	for i := 0; i < 20; i++ {
+		if _, ok := r.Process(&LogEntry{Message: "m"}); ok {
+			passed++
+		}
+	}
+	if passed != 5 {
+		t.Errorf("burst passed = %d, want 5", passed)
+	}
+
+	// 0.3秒补充3个令牌
+	now = now.Add(300 * time.Millisecond)
+	passed = 0
+	for i := 0; i < 20; i++ {
+		if _, ok := r.Process(&LogEntry{Message: "m"}); ok {
+			passed++
+		}
+	}
+	if passed != 3 {
+		t.Errorf("refilled passed = %d, want 3", passed)
+	}
+	if got := metricRateLimitedEntries.get("loki") - before; got != 32 {
+		t.Errorf("dropped metric = %v, want 32", got)
+	}
+}
+
+func TestRateLimiterBytes(t *testing.T) {
+	r := NewRateLimiter("kafka", RateLimitConfig{Bytes: 100, ReportInterval: -1})
+	now := time.Unix(1700000000, 0)
+	r.now = func() time.Time { return now }
+
+	big := &LogEntry{Message: strings.Repeat("x", 60)}
+	if _, ok := r.Process(big); !ok {
+		t.Fatal("first entry must pass")
+	}
+	if _, ok := r.Process(big); ok {
+		t.Error("second entry exceeds the byte budget")
+	}
+	if _, ok := r.Process(&LogEntry{Message: "small"}); !ok {
+		t.Error("small entry fits the remaining budget")
+	}
+	// 超过桶容量的日志在桶满时仍可通过
+	now = now.Add(time.Second)
+	if _, ok := r.Process(&LogEntry{Message: strings.Repeat("x", 500)}); !ok {
+		t.Error("oversized entry must pass with a full bucket")
+	}
+}
+
+func TestRateLimitInInit(t *testing.T) {
+	limited, all := NewRingWriter(100), NewRingWriter(100)
+	Init(Config{
+		Labels:     map[string]string{"service": "api"},
+		Writers:    map[string]Writer{"limited": limited, "all": all},
+		RateLimits: map[string]RateLimitConfig{"limited": {Lines: 1, BurstLines: 2, ReportInterval: 50 * time.Millisecond}},
+	})
+	defer Init(Config{})
+
+	for i := 0; i < 10; i++ {
+		Info("storm")
+	}
+	if all.Len() != 10 || limited.Len() != 2 {
+		t.Fatalf("all=%d limited=%d, want 10 and 2", all.Len(), limited.Len())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for limited.Len() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := limited.Entries(RingFilter{})
+	if len(entries) != 3 {
+		t.Fatalf("limited entries = %d, want 3", len(entries))
+	}
+	report := entries[2]
+	if report.Message != "dropped 8 entries" || report.Fields["dropped_entries"] != 8 || report.Fields["writer"] != "limited" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Labels["service"] != "api" || report.Labels["level"] != "warn" {
+		t.Errorf("report labels = %v", report.Labels)
+	}
+	if all.Len() != 10 {
+		t.Errorf("report must only go to the limited writer, all=%d", all.Len())
+	}
+}
@@ -0,0 +1,155 @@
+package reader
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/TEENet-io/logdashboard/pkg/log"
+)
+
+// Query 查询条件，零值匹配全部日志
+// From/To: 时间范围[From, To)，零值表示不限制
+// MinLevel: 最低级别；Levels: 只匹配列出的级别
+// Labels: 标签匹配器，全部满足才匹配
+// Fields: 字段谓词，全部满足才匹配
+type Query struct {
+	From     time.Time        // 起始时间（含）
+	To       time.Time        // 结束时间（不含）
+	MinLevel string           // 最低级别
+	Levels   []string         // 级别集合
+	Labels   []LabelMatcher   // 标签匹配器
+	Fields   []FieldPredicate // 字段谓词
+}
+
+// Match 判断日志条目是否满足查询条件
+func (q Query) Match(entry *log.LogEntry) bool {
+	if !q.From.IsZero() && entry.Time < q.From.Unix() {
+		return false
+	}
+	if !q.To.IsZero() && entry.Time >= q.To.Unix() {
+		return false
+	}
+	if q.MinLevel != "" {
+		lp, _ := log.LevelPriority(entry.Level)
+		mp, _ := log.LevelPriority(q.MinLevel)
+		if lp < mp {
+			return false
+		}
+	}
+	if len(q.Levels) > 0 {
+		found := false
+		for _, l := range q.Levels {
+			if l == entry.Level {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, m := range q.Labels {
+		if !m.Match(entry.Labels) {
+			return false
+		}
+	}
+	for _, p := range q.Fields {
+		if !p(entry.Fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// 标签匹配操作符，与LogQL一致
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// LabelMatcher 标签匹配器
+type LabelMatcher struct {
+	Name  string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+// NewLabelMatcher 创建标签匹配器，正则操作符要求整个值匹配
+func NewLabelMatcher(name, op, value string) (LabelMatcher, error) {
+	m := LabelMatcher{Name: name, Op: op, Value: value}
+	switch op {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return m, fmt.Errorf("invalid label regexp %q: %w", value, err)
+		}
+		m.re = re
+	default:
+		return m, fmt.Errorf("unknown label match operator %q", op)
+	}
+	return m, nil
+}
+
+// MustLabelMatcher 同NewLabelMatcher，出错时panic
+func MustLabelMatcher(name, op, value string) LabelMatcher {
+	m, err := NewLabelMatcher(name, op, value)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// LabelEquals 标签等于给定值
+func LabelEquals(name, value string) LabelMatcher {
+	return LabelMatcher{Name: name, Op: MatchEqual, Value: value}
+}
+
+// Match 判断标签集合是否匹配，缺失的标签视为空字符串
+func (m LabelMatcher) Match(labels map[string]string) bool {
+	v := labels[m.Name]
+	switch m.Op {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re != nil && m.re.MatchString(v)
+	case MatchNotRegexp:
+		return m.re != nil && !m.re.MatchString(v)
+	}
+	return false
+}
+
+// FieldPredicate 字段谓词
+type FieldPredicate func(fields map[string]interface{}) bool
+
+// FieldExists 字段存在
+func FieldExists(key string) FieldPredicate {
+	return func(fields map[string]interface{}) bool {
+		_, ok := fields[key]
+		return ok
+	}
+}
+
+// FieldEquals 字段的字符串形式等于value
+// 从文件读回的数字为json.Number，因此按字符串形式比较
+func FieldEquals(key string, value interface{}) FieldPredicate {
+	want := fmt.Sprint(value)
+	return func(fields map[string]interface{}) bool {
+		v, ok := fields[key]
+		return ok && fmt.Sprint(v) == want
+	}
+}
+
+// FieldMatches 字段的字符串形式匹配正则
+func FieldMatches(key string, re *regexp.Regexp) FieldPredicate {
+	return func(fields map[string]interface{}) bool {
+		v, ok := fields[key]
+		return ok && re.MatchString(fmt.Sprint(v))
+	}
+}
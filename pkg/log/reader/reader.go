@@ -0,0 +1,160 @@
+// Package reader 读取FileWriter写入的本地日志文件，按条件查询日志条目
+// 支持轮转历史文件、gzip压缩文件、审计模式与加密文件
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+
+	"github.com/TEENet-io/logdashboard/pkg/log"
+)
+
+// Options 读取选项
+// Encoder: 写入时使用的编码配置，零值为默认编码
+// KeyProvider: 读取加密文件时使用的密钥提供者
+// SkipRotated: 只读取当前文件，不读取轮转产生的历史文件
+// SkipMalformed: 跳过无法解析的行而不是返回错误
+type Options struct {
+	Encoder       log.EncoderConfig // 编码配置
+	KeyProvider   log.KeyProvider   // 解密密钥
+	SkipRotated   bool              // 不读取历史文件
+	SkipMalformed bool              // 跳过无法解析的行
+}
+
+// Segments 返回path对应的全部文件段：历史文件（从旧到新）加当前文件
+func Segments(path string, opts Options) ([]string, error) {
+	var segs []string
+	if !opts.SkipRotated {
+		backups, err := log.BackupFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, backups...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		segs = append(segs, path)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return segs, nil
+}
+
+// Entries 按时间顺序遍历path及其历史文件中满足查询条件的日志条目
+// 遍历出错时产出(nil, err)并结束
+func Entries(path string, q Query, opts Options) iter.Seq2[*log.LogEntry, error] {
+	return func(yield func(*log.LogEntry, error) bool) {
+		segs, err := Segments(path, opts)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, seg := range segs {
+			for entry, err := range FileEntries(seg, q, opts) {
+				if !yield(entry, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FileEntries 遍历单个文件段（可为.gz或加密文件）中满足查询条件的日志条目
+func FileEntries(path string, q Query, opts Options) iter.Seq2[*log.LogEntry, error] {
+	return func(yield func(*log.LogEntry, error) bool) {
+		f, err := os.Open(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer f.Close()
+
+		r, err := plainReader(path, f, opts)
+		if err != nil {
+			yield(nil, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+
+		enc := log.NewEncoder(opts.Encoder)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		lineNo := 0
+		for sc.Scan() {
+			lineNo++
+			if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+				continue
+			}
+			entry, err := log.ParseFileLine(sc.Bytes(), enc)
+			if err != nil {
+				if opts.SkipMalformed {
+					continue
+				}
+				yield(nil, fmt.Errorf("%s:%d: %w", path, lineNo, err))
+				return
+			}
+			if entry == nil || !q.Match(entry) {
+				continue
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+		if err := sc.Err(); err != nil {
+			yield(nil, fmt.Errorf("%s: %w", path, err))
+		}
+	}
+}
+
+// plainReader 按需解压、解密，返回明文日志行流
+func plainReader(path string, f *os.File, opts Options) (io.Reader, error) {
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	}
+
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(8)
+	if !log.IsEncrypted(prefix) {
+		return br, nil
+	}
+	if opts.KeyProvider == nil {
+		return nil, fmt.Errorf("file is encrypted but no KeyProvider is configured")
+	}
+	return log.NewDecryptReader(br, opts.KeyProvider)
+}
+
+// ReadAll 读取全部满足条件的日志条目
+func ReadAll(path string, q Query, opts Options) ([]*log.LogEntry, error) {
+	var entries []*log.LogEntry
+	for entry, err := range Entries(path, q, opts) {
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Replay 将遍历到的日志条目依次写入w，例如回填到LokiWriter，返回成功写入的条数
+func Replay(entries iter.Seq2[*log.LogEntry, error], w log.Writer) (int, error) {
+	n := 0
+	for entry, err := range entries {
+		if err != nil {
+			return n, err
+		}
+		if err := w.Write(entry); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
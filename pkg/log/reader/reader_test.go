@@ -0,0 +1,115 @@
+package reader
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TEENet-io/logdashboard/pkg/log"
+)
+
+func writeEntries(t *testing.T, c log.FileWriterConfig, enc log.EncoderConfig, entries []*log.LogEntry) {
+	t.Helper()
+	fw, err := log.NewFileWriterWithConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.SetEncoder(log.NewEncoder(enc))
+	for _, e := range entries {
+		if err := fw.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sampleEntries(base time.Time) []*log.LogEntry {
+	var entries []*log.LogEntry
+	levels := []string{"debug", "info", "warn", "error"}
+	for i := 0; i < 40; i++ {
+		entries = append(entries, &log.LogEntry{
+			Level:   levels[i%4],
+			Message: "event " + strings.Repeat("x", 20),
+			Labels:  map[string]string{"service": []string{"api", "worker"}[i%2]},
+			Fields:  map[string]interface{}{"i": i},
+			Time:    base.Add(time.Duration(i) * time.Second).Unix(),
+		})
+	}
+	return entries
+}
+
+func TestReadRotatedSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	base := time.Unix(1700000000, 0)
+	writeEntries(t, log.FileWriterConfig{
+		Path:   path,
+		Rotate: log.RotateConfig{MaxSize: 300, Compress: true},
+	}, log.EncoderConfig{}, sampleEntries(base))
+
+	segs, err := Segments(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) < 3 {
+		t.Fatalf("expected rotated segments, got %v", segs)
+	}
+
+	all, err := ReadAll(path, Query{}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 40 {
+		t.Fatalf("read %d entries, want 40", len(all))
+	}
+	for i, e := range all {
+		if e.Time != base.Unix()+int64(i) {
+			t.Fatalf("entry %d out of order: ts=%d", i, e.Time)
+		}
+	}
+
+	got, err := ReadAll(path, Query{
+		From:     base.Add(10 * time.Second),
+		To:       base.Add(30 * time.Second),
+		MinLevel: "warn",
+		Labels:   []LabelMatcher{MustLabelMatcher("service", MatchRegexp, "api|web")},
+		Fields:   []FieldPredicate{FieldExists("i")},
+	}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10..29中级别为warn/error且service为api的条目：i%4==2
+	if len(got) != 5 {
+		t.Fatalf("filtered %d entries, want 5", len(got))
+	}
+	for _, e := range got {
+		if e.Level != "warn" || e.Labels["service"] != "api" {
+			t.Errorf("unexpected entry %+v", e)
+		}
+	}
+}
+
+func TestReadEncryptedAuditFlattened(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secure.log")
+	kp := log.KeyProviderFunc(func(string) ([]byte, error) { return []byte("0123456789abcdef"), nil })
+	enc := log.ECSEncoderConfig()
+	writeEntries(t, log.FileWriterConfig{
+		Path:       path,
+		Audit:      &log.AuditConfig{},
+		Encryption: &log.EncryptionConfig{KeyProvider: kp},
+	}, enc, sampleEntries(time.Unix(1700000000, 0)))
+
+	if _, err := ReadAll(path, Query{}, Options{Encoder: enc}); err == nil {
+		t.Fatal("expected error reading encrypted file without key")
+	}
+
+	got, err := ReadAll(path, Query{Fields: []FieldPredicate{FieldEquals("i", 7)}}, Options{Encoder: enc, KeyProvider: kp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Level != "error" || got[0].Labels["service"] != "worker" {
+		t.Fatalf("unexpected result %+v", got)
+	}
+}
@@ -0,0 +1,299 @@
+package log
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// 脱敏方式
+const (
+	RedactMask = "mask" // 替换为掩码
+	RedactHash = "hash" // 替换为哈希，相同的值得到相同结果，便于关联
+	RedactDrop = "drop" // 删除字段；Message中的匹配内容按mask处理
+)
+
+var metricRedactBlocked = newCounterVec("log_redact_blocked_total",
+	"Entries dropped because the redaction config is invalid.", "level")
+
+// DefaultRedactKeys 默认的敏感键名，比较时忽略大小写与"_"、"-"，键名包含其中任一项即视为敏感
+var DefaultRedactKeys = []string{
+	"password", "passwd", "pwd", "secret", "token", "authorization",
+	"cookie", "apikey", "accesskey", "privatekey", "credential", "session",
+}
+
+// DefaultRedactPatterns 默认的敏感值正则：JWT、PEM块、64位十六进制私钥、邮箱、手机号
+// 注意64位十六进制也会匹配同长度的哈希值，可按需自定义Patterns
+var DefaultRedactPatterns = []string{
+	`eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`,
+	`(?s)-----BEGIN [A-Z0-9 ]+-----.*?-----END [A-Z0-9 ]+-----`,
+	`\b(?:0x)?[0-9a-fA-F]{64}\b`,
+	`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	`(?:\+\d{1,3}[- ]?)?\b1[3-9]\d{9}\b`,
+	`\+\d{1,3}[- ]?\d{6,14}\b`,
+}
+
+// RedactConfig 脱敏处理器配置
+// Keys: 敏感键名，nil时使用DefaultRedactKeys；命中的字段整体脱敏，嵌套map与结构体中的键同样生效
+// Patterns: 敏感值正则，nil时使用DefaultRedactPatterns；字符串中匹配的部分被脱敏
+// Strategy: "mask"（默认）、"hash"或"drop"
+// HashKey: 非空时hash使用HMAC-SHA256，防止通过字典反推原值
+// SkipMessage: 为true时不扫描Message
+type RedactConfig struct {
+	Keys        []string // 敏感键名
+	Patterns    []string // 敏感值正则
+	Strategy    string   // 脱敏方式
+	Mask        string   // 掩码，默认"[REDACTED]"
+	HashKey     []byte   // hash密钥
+	SkipMessage bool     // 不扫描Message
+}
+
+// Redactor 敏感信息脱敏处理器，实现Processor接口
+type Redactor struct {
+	cfg      RedactConfig
+	keys     []string
+	patterns []*regexp.Regexp
+}
+
+// NewRedactor 创建脱敏处理器
+func NewRedactor(c RedactConfig) (*Redactor, error) {
+	if c.Strategy == "" {
+		c.Strategy = RedactMask
+	}
+	if c.Strategy != RedactMask && c.Strategy != RedactHash && c.Strategy != RedactDrop {
+		return nil, fmt.Errorf("unknown redact strategy %q", c.Strategy)
+	}
+	if c.Mask == "" {
+		c.Mask = "[REDACTED]"
+	}
+	if c.Keys == nil {
+		c.Keys = DefaultRedactKeys
+	}
+	if c.Patterns == nil {
+		c.Patterns = DefaultRedactPatterns
+	}
+
+	r := &Redactor{cfg: c}
+	for _, k := range c.Keys {
+		r.keys = append(r.keys, normalizeRedactKey(k))
+	}
+	for _, p := range c.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redactBlocker 脱敏配置无效时代替Redactor，丢弃全部日志
+type redactBlocker struct{}
+
+// Process 实现Processor接口
+func (redactBlocker) Process(entry *LogEntry) (*LogEntry, bool) {
+	metricRedactBlocked.inc(entry.Level)
+	return nil, false
+}
+
+// Process 实现Processor接口
+func (r *Redactor) Process(entry *LogEntry) (*LogEntry, bool) {
+	if !r.cfg.SkipMessage {
+		strategy := r.cfg.Strategy
+		if strategy == RedactDrop {
+			strategy = RedactMask
+		}
+		entry.Message, _ = r.redactString(entry.Message, strategy)
+	}
+	for k, v := range entry.Fields {
+		nv, changed, drop := r.redactField(k, v)
+		switch {
+		case drop:
+			delete(entry.Fields, k)
+		case changed:
+			entry.Fields[k] = nv
+		}
+	}
+	return entry, true
+}
+
+func normalizeRedactKey(k string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+}
+
+// sensitiveKey 键名是否包含敏感词
+func (r *Redactor) sensitiveKey(k string) bool {
+	nk := normalizeRedactKey(k)
+	for _, deny := range r.keys {
+		if deny != "" && strings.Contains(nk, deny) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactField 脱敏一个键值对，返回新值、是否变化、是否应删除
+func (r *Redactor) redactField(k string, v interface{}) (interface{}, bool, bool) {
+	if r.sensitiveKey(k) {
+		switch r.cfg.Strategy {
+		case RedactDrop:
+			return nil, true, true
+		case RedactHash:
+			return r.hash(fieldString(v)), true, false
+		}
+		return r.cfg.Mask, true, false
+	}
+	return r.redactValue(v)
+}
+
+// redactValue 递归脱敏值；嵌套的map、切片与结构体会被复制，不修改调用方的数据
+func (r *Redactor) redactValue(v interface{}) (interface{}, bool, bool) {
+	switch tv := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, false, false
+	case string:
+		s, changed := r.redactString(tv, r.cfg.Strategy)
+		if changed && r.cfg.Strategy == RedactDrop {
+			return nil, true, true
+		}
+		return s, changed, false
+	case []byte:
+		return v, false, false
+	case error:
+		return r.redactValue(tv.Error())
+	case map[string]interface{}:
+		var out map[string]interface{}
+		for k, ev := range tv {
+			nv, changed, drop := r.redactField(k, ev)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(tv))
+				for ok, ov := range tv {
+					out[ok] = ov
+				}
+			}
+			if drop {
+				delete(out, k)
+			} else {
+				out[k] = nv
+			}
+		}
+		if out == nil {
+			return v, false, false
+		}
+		return out, true, false
+	case map[string]string:
+		generic := make(map[string]interface{}, len(tv))
+		for k, ev := range tv {
+			generic[k] = ev
+		}
+		if nv, changed, _ := r.redactValue(generic); changed {
+			return nv, true, false
+		}
+		return v, false, false
+	case []interface{}:
+		var out []interface{}
+		for i, ev := range tv {
+			nv, changed, drop := r.redactValue(ev)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}{}, tv...)
+			}
+			if drop {
+				nv = r.cfg.Mask
+			}
+			out[i] = nv
+		}
+		if out == nil {
+			return v, false, false
+		}
+		return out, true, false
+	case fmt.Stringer:
+		// 基本类型的命名类型（如time.Duration）按其字符串形式扫描
+		if kind := reflect.TypeOf(v).Kind(); kind != reflect.Struct && kind != reflect.Pointer {
+			return r.redactValue(tv.String())
+		}
+	}
+
+	// 结构体、其他类型的map与切片经JSON转为通用结构后递归处理；
+	// 含结构体的值总是替换为脱敏后的JSON形式，避免写入器用fmt输出未导出或json:"-"字段
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return v, false, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return r.redactValue(fmt.Sprint(v))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return r.redactValue(fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return r.redactValue(fmt.Sprint(v))
+	}
+	nv, changed, drop := r.redactValue(generic)
+	if changed || hidesFields(rv.Type()) {
+		return nv, true, drop
+	}
+	return v, false, false
+}
+
+// hidesFields 类型是否可能包含JSON形式中看不到的字段（结构体或接口元素）
+func hidesFields(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hidesFields(t.Elem())
+	case reflect.Map:
+		return hidesFields(t.Key()) || hidesFields(t.Elem())
+	}
+	return false
+}
+
+// redactString 替换字符串中匹配的敏感内容
+func (r *Redactor) redactString(s string, strategy string) (string, bool) {
+	changed := false
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			changed = true
+			if strategy == RedactHash {
+				return r.hash(m)
+			}
+			return r.cfg.Mask
+		})
+	}
+	return s, changed
+}
+
+// hash 返回"sha256:"加16位十六进制摘要
+func (r *Redactor) hash(s string) string {
+	var sum []byte
+	if len(r.cfg.HashKey) > 0 {
+		m := hmac.New(sha256.New, r.cfg.HashKey)
+		m.Write([]byte(s))
+		sum = m.Sum(nil)
+	} else {
+		h := sha256.Sum256([]byte(s))
+		sum = h[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
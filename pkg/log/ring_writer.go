@@ -0,0 +1,297 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RingWriter 内存环形缓冲写入器，实现Writer接口
+// 保留最近Size条日志，可通过Handler在进程内直接查看，Loki不可用时用于排查问题
+
+type RingWriter struct {
+	mu      sync.Mutex
+	buf     []*LogEntry
+	next    int  // 下一条写入的位置
+	full    bool // 缓冲区是否已写满一轮
+	encoder *Encoder
+	subs    map[chan *LogEntry]struct{}
+}
+
+// RingFilter 缓冲区查询条件，零值匹配全部日志
+// Fields: 键为字段名，值为空时只要求字段存在，否则要求fmt.Sprint(值)相等
+type RingFilter struct {
+	MinLevel string            // 最低级别
+	Since    time.Time         // 起始时间（含）
+	Until    time.Time         // 结束时间（含）
+	Labels   map[string]string // 标签相等
+	Fields   map[string]string // 字段存在或相等
+	Contains string            // 消息包含的子串
+	Limit    int               // 只返回最近的Limit条，0为不限制
+}
+
+// ringSubscriberBuffer 每个跟随连接的缓冲，客户端过慢时丢弃新日志
+const ringSubscriberBuffer = 256
+
+// NewRingWriter 创建保留最近size条日志的环形缓冲写入器，size<=0时为10000
+func NewRingWriter(size int) *RingWriter {
+	if size <= 0 {
+		size = 10000
+	}
+	return &RingWriter{buf: make([]*LogEntry, size), subs: map[chan *LogEntry]struct{}{}}
+}
+
+// SetEncoder 设置HTTP接口输出JSON时的编码器
+func (rw *RingWriter) SetEncoder(enc *Encoder) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.encoder = enc
+}
+
+// Write 实现Writer接口，写满后覆盖最旧的日志
+func (rw *RingWriter) Write(entry *LogEntry) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.buf[rw.next] = entry
+	rw.next++
+	if rw.next == len(rw.buf) {
+		rw.next = 0
+		rw.full = true
+	}
+	for ch := range rw.subs {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+	return nil
+}
+
+// Entries 按时间从旧到新返回匹配的日志
+func (rw *RingWriter) Entries(f RingFilter) []*LogEntry {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.entries(f)
+}
+
+func (rw *RingWriter) entries(f RingFilter) []*LogEntry {
+	var out []*LogEntry
+	start, n := 0, rw.next
+	if rw.full {
+		start, n = rw.next, len(rw.buf)
+	}
+	for i := 0; i < n; i++ {
+		entry := rw.buf[(start+i)%len(rw.buf)]
+		if f.Match(entry) {
+			out = append(out, entry)
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// Len 返回缓冲区中的日志条数
+func (rw *RingWriter) Len() int {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.full {
+		return len(rw.buf)
+	}
+	return rw.next
+}
+
+// subscribe 返回当前匹配的日志并订阅之后的新日志，两者之间不会遗漏
+func (rw *RingWriter) subscribe(f RingFilter) ([]*LogEntry, chan *LogEntry) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	ch := make(chan *LogEntry, ringSubscriberBuffer)
+	rw.subs[ch] = struct{}{}
+	return rw.entries(f), ch
+}
+
+func (rw *RingWriter) unsubscribe(ch chan *LogEntry) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	delete(rw.subs, ch)
+}
+
+// Match 判断日志是否满足查询条件
+func (f RingFilter) Match(entry *LogEntry) bool {
+	if f.MinLevel != "" && levelPriority[entry.Level] < levelPriority[f.MinLevel] {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time < f.Since.Unix() {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time > f.Until.Unix() {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(entry.Message, f.Contains) {
+		return false
+	}
+	for k, v := range f.Labels {
+		if entry.Labels[k] != v {
+			return false
+		}
+	}
+	for k, v := range f.Fields {
+		fv, ok := entry.Fields[k]
+		if !ok || (v != "" && fmt.Sprint(fv) != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseRingFilter 从URL查询参数解析查询条件
+// level=warn since=15m|RFC3339 until=RFC3339 label=k:v field=k[:v] q=子串 limit=N
+func ParseRingFilter(q url.Values) (RingFilter, error) {
+	f := RingFilter{MinLevel: q.Get("level"), Contains: q.Get("q")}
+	if f.MinLevel != "" {
+		if _, ok := levelPriority[f.MinLevel]; !ok {
+			return f, fmt.Errorf("unknown level %q", f.MinLevel)
+		}
+	}
+	var err error
+	if f.Since, err = parseRingTime(q.Get("since")); err != nil {
+		return f, err
+	}
+	if f.Until, err = parseRingTime(q.Get("until")); err != nil {
+		return f, err
+	}
+	for _, l := range q["label"] {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			return f, fmt.Errorf("invalid label filter %q, want key:value", l)
+		}
+		if f.Labels == nil {
+			f.Labels = map[string]string{}
+		}
+		f.Labels[k] = v
+	}
+	for _, fl := range q["field"] {
+		k, v, _ := strings.Cut(fl, ":")
+		if f.Fields == nil {
+			f.Fields = map[string]string{}
+		}
+		f.Fields[k] = v
+	}
+	if s := q.Get("limit"); s != "" {
+		if f.Limit, err = strconv.Atoi(s); err != nil || f.Limit < 0 {
+			return f, fmt.Errorf("invalid limit %q", s)
+		}
+	}
+	return f, nil
+}
+
+// parseRingTime 解析RFC3339时间或相对当前的时长（如"15m"）
+func parseRingTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, want RFC3339 or duration", s)
+	}
+	return t, nil
+}
+
+// Handler 返回查看缓冲区的HTTP接口
+// 查询参数见ParseRingFilter；format=text输出文本视图，默认JSON数组；
+// follow=1时以SSE（text/event-stream）先推送已有日志再持续推送新日志
+func (rw *RingWriter) Handler() http.Handler {
+	return http.HandlerFunc(rw.serveHTTP)
+}
+
+func (rw *RingWriter) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, err := ParseRingFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text := q.Get("format") == "text"
+
+	rw.mu.Lock()
+	enc := rw.encoder
+	rw.mu.Unlock()
+	format := func(entry *LogEntry) string {
+		if text {
+			return formatText(entry, false)
+		}
+		line, err := encodeEntry(enc, entry)
+		if err != nil {
+			return `{"msg":"failed to encode log entry"}`
+		}
+		return line
+	}
+
+	if q.Get("follow") == "1" {
+		rw.follow(w, r, f, format)
+		return
+	}
+
+	entries := rw.Entries(f)
+	if text {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, entry := range entries {
+			fmt.Fprintln(w, format(entry))
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = format(entry)
+	}
+	fmt.Fprintf(w, "[%s]\n", strings.Join(lines, ","))
+}
+
+// follow 以SSE持续推送匹配的日志，直到客户端断开
+func (rw *RingWriter) follow(w http.ResponseWriter, r *http.Request, f RingFilter, format func(*LogEntry) string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	backlog, ch := rw.subscribe(f)
+	defer rw.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	send := func(entry *LogEntry) {
+		// 文本视图中的换行需拆分为多个data行
+		for _, line := range strings.Split(format(entry), "\n") {
+			fmt.Fprintf(w, "data: %s\n", line)
+		}
+		fmt.Fprint(w, "\n")
+	}
+	for _, entry := range backlog {
+		send(entry)
+	}
+	flusher.Flush()
+
+	f.Limit = 0
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry := <-ch:
+			if f.Match(entry) {
+				send(entry)
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingWriterWrapAndFilter(t *testing.T) {
+	rw := NewRingWriter(3)
+	for i, lvl := range []string{"info", "warn", "error", "info", "error"} {
+		_ = rw.Write(&LogEntry{Level: lvl, Message: "m" + string(rune('0'+i)), Fields: map[string]interface{}{"n": i}, Time: int64(100 + i)})
+	}
+	if rw.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", rw.Len())
+	}
+	var msgs []string
+	for _, e := range rw.Entries(RingFilter{}) {
+		msgs = append(msgs, e.Message)
+	}
+	if strings.Join(msgs, ",") != "m2,m3,m4" {
+		t.Errorf("entries = %v, want oldest overwritten", msgs)
+	}
+	if got := rw.Entries(RingFilter{MinLevel: "error"}); len(got) != 2 {
+		t.Errorf("error entries = %d, want 2", len(got))
+	}
+	if got := rw.Entries(RingFilter{Fields: map[string]string{"n": "3"}}); len(got) != 1 || got[0].Message != "m3" {
+		t.Errorf("field filter = %v", got)
+	}
+	if got := rw.Entries(RingFilter{Since: time.Unix(103, 0), Limit: 1}); len(got) != 1 || got[0].Message != "m4" {
+		t.Errorf("since/limit filter = %v", got)
+	}
+}
+
+func TestRingWriterHandler(t *testing.T) {
+	rw := NewRingWriter(10)
+	_ = rw.Write(&LogEntry{Level: "info", Message: "hello", Labels: map[string]string{"service": "api"}, Time: 1700000000})
+	_ = rw.Write(&LogEntry{Level: "error", Message: "boom", Labels: map[string]string{"service": "worker"}, Time: 1700000001})
+	srv := httptest.NewServer(rw.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?label=service:api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&entries)
+	resp.Body.Close()
+	if len(entries) != 1 || entries[0]["msg"] != "hello" {
+		t.Errorf("json view = %v", entries)
+	}
+
+	resp, _ = http.Get(srv.URL + "?format=text&level=error")
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "ERROR boom") || strings.Contains(string(body), "hello") {
+		t.Errorf("text view = %q", body)
+	}
+
+	resp, _ = http.Get(srv.URL + "?level=fatal")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid level status = %d", resp.StatusCode)
+	}
+}
+
+func TestRingWriterFollow(t *testing.T) {
+	rw := NewRingWriter(10)
+	_ = rw.Write(&LogEntry{Level: "error", Message: "old", Time: 1})
+	srv := httptest.NewServer(rw.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?follow=1&level=warn", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		_, _ = r.ReadString('\n') // 事件间的空行
+		return line
+	}
+	if ev := readEvent(); !strings.Contains(ev, `"msg":"old"`) {
+		t.Errorf("backlog event = %q", ev)
+	}
+	_ = rw.Write(&LogEntry{Level: "info", Message: "filtered", Time: 2})
+	_ = rw.Write(&LogEntry{Level: "warn", Message: "new", Time: 3})
+	if ev := readEvent(); !strings.HasPrefix(ev, "data: ") || !strings.Contains(ev, `"msg":"new"`) {
+		t.Errorf("live event = %q", ev)
+	}
+}
@@ -0,0 +1,245 @@
+package log
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat 轮转文件名中的时间格式，按字典序即按时间排序
+const backupTimeFormat = "20060102T150405.000"
+
+// RotateConfig 日志文件轮转配置
+// MaxSize: 单个文件最大字节数，超过后轮转，0表示不按大小轮转
+// Interval: 按时间轮转的周期，0表示不按时间轮转
+// MaxBackups: 保留的历史文件个数，0表示不限制
+// MaxAge: 历史文件最长保留时间，0表示不限制
+// Compress: 是否gzip压缩历史文件
+type RotateConfig struct {
+	MaxSize    int64         // 最大字节数
+	Interval   time.Duration // 轮转周期
+	MaxBackups int           // 保留个数
+	MaxAge     time.Duration // 保留时间
+	Compress   bool          // gzip压缩
+}
+
+// shouldRotate 判断写入n字节前是否需要轮转
+func (fw *FileWriter) shouldRotate(n int64) bool {
+	if fw.rotate.MaxSize > 0 && fw.size > 0 && fw.size+n > fw.rotate.MaxSize {
+		return true
+	}
+	if fw.rotate.Interval > 0 && time.Since(fw.openedAt) >= fw.rotate.Interval {
+		return true
+	}
+	return false
+}
+
+// rotateFile 将当前文件重命名为带时间戳的历史文件并重新打开
+// 压缩与过期清理在后台进行，调用方需持有fw.mu
+func (fw *FileWriter) rotateFile() error {
+	if err := fw.sealAudit(); err != nil {
+		return err
+	}
+	if err := fw.file.Close(); err != nil {
+		return err
+	}
+	fw.file = nil
+
+	backup := backupName(fw.filePath, time.Now())
+	if err := os.Rename(fw.filePath, backup); err != nil {
+		return err
+	}
+	if fw.audit != nil {
+		fw.audit.prevFile = filepath.Base(backup)
+	}
+	if err := fw.open(); err != nil {
+		return err
+	}
+
+	rc := fw.rotate
+	path := fw.filePath
+	fw.bg.Add(1)
+	go func() {
+		defer fw.bg.Done()
+		// 串行执行，避免压缩中的文件被并发清理重复计数
+		fw.bgMu.Lock()
+		defer fw.bgMu.Unlock()
+		if rc.Compress && compressFile(backup, fw.perm) == nil {
+			_ = fw.applyOwnership(backup + ".gz")
+		}
+		_ = cleanupBackups(path, rc)
+	}()
+	return nil
+}
+
+// backupName 生成历史文件名，如 app.log -> app-20060102T150405.000.log
+func backupName(path string, t time.Time) string {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext)
+
+	name := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext))
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s-%s-%d%s", prefix, t.Format(backupTimeFormat), i, ext))
+	}
+	return name
+}
+
+// listBackups 返回path的历史文件（含压缩文件），按时间从旧到新排序
+func listBackups(path string) ([]string, error) {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path  string
+		stamp string
+		n     int
+	}
+	var found []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		stamp = strings.TrimPrefix(stamp, prefix)
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp[:len(backupTimeFormat)]); err != nil {
+			continue
+		}
+		// 同一毫秒内多次轮转时带有"-N"后缀
+		b := backup{path: filepath.Join(dir, name), stamp: stamp[:len(backupTimeFormat)]}
+		if rest := stamp[len(backupTimeFormat):]; rest != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(rest, "-"))
+			if err != nil || !strings.HasPrefix(rest, "-") {
+				continue
+			}
+			b.n = n
+		}
+		found = append(found, b)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].stamp != found[j].stamp {
+			return found[i].stamp < found[j].stamp
+		}
+		return found[i].n < found[j].n
+	})
+
+	backups := make([]string, len(found))
+	for i, b := range found {
+		backups[i] = b.path
+	}
+	return backups, nil
+}
+
+// cleanupBackups 按MaxBackups与MaxAge删除过期历史文件
+func cleanupBackups(path string, rc RotateConfig) error {
+	if rc.MaxBackups <= 0 && rc.MaxAge <= 0 {
+		return nil
+	}
+	backups, err := listBackups(path)
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	if rc.MaxBackups > 0 && len(backups) > rc.MaxBackups {
+		remove = append(remove, backups[:len(backups)-rc.MaxBackups]...)
+		backups = backups[len(backups)-rc.MaxBackups:]
+	}
+	if rc.MaxAge > 0 {
+		cutoff := time.Now().Add(-rc.MaxAge)
+		for _, b := range backups {
+			info, err := os.Stat(b)
+			if err == nil && info.ModTime().Before(cutoff) {
+				remove = append(remove, b)
+			}
+		}
+	}
+
+	for _, b := range remove {
+		if err := os.Remove(b); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressFile 将文件压缩为.gz并删除原文件
+func compressFile(path string, perm os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// openLogFile 打开日志文件，.gz文件自动解压
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+// gzipFile 关闭时同时关闭解压器和底层文件
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
@@ -0,0 +1,193 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// RouteRule 路由规则，按顺序匹配，日志被分发到所有命中规则的写入器
+// Levels: 匹配的级别，为空时匹配全部级别
+// Labels/Fields: 名称到正则的映射，值须完整匹配；不存在的标签或字段按空字符串匹配
+// Writers: 目标写入器名称，内置写入器名称为file、console、syslog、loki、sls、otlp、
+// elastic、forward、gelf、webhook、kafka，Files中的文件为其Name（默认Path），自定义写入器为Writers中的键；
+// 为空时丢弃匹配的日志；名称均不存在时（拼写错误或写入器创建失败）同样丢弃，错误通过InitError报告
+// Continue: 命中后继续匹配后续规则，默认命中即停止
+// 未命中任何规则的日志分发到所有写入器
+type RouteRule struct {
+	Levels   []string          // 匹配的级别
+	Labels   map[string]string // 标签值正则
+	Fields   map[string]string // 字段值正则
+	Writers  []string          // 目标写入器名称
+	Continue bool              // 命中后是否继续
+}
+
+// route 编译后的路由规则
+type route struct {
+	levels  map[string]bool
+	labels  map[string]*regexp.Regexp
+	fields  map[string]*regexp.Regexp
+	writers []int // loggers中的下标
+	cont    bool
+}
+
+// router 路由表，nil表示分发到所有写入器
+type router struct {
+	routes []route
+}
+
+// ValidateRoutes 检查路由规则中的级别与正则是否有效
+// 给出writers（全部写入器名称）时同时检查规则引用的写入器是否存在
+func ValidateRoutes(rules []RouteRule, writers ...string) error {
+	for i, rule := range rules {
+		_, err := compileRoute(rule, writers)
+		if err == nil && len(writers) > 0 {
+			err = unknownWriters(rule, writers)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid route rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// newRouter 编译路由规则，返回的错误包含全部无效规则与不存在的写入器名称
+// 无效规则被忽略，其日志按未命中处理；目标写入器均不存在的规则丢弃匹配的日志
+func newRouter(rules []RouteRule, names []string) (*router, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	r := &router{}
+	var errs []error
+	for i, rule := range rules {
+		rt, err := compileRoute(rule, names)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid route rule %d: %w", i, err))
+			continue
+		}
+		if err := unknownWriters(rule, names); err != nil {
+			errs = append(errs, fmt.Errorf("invalid route rule %d: %w", i, err))
+		}
+		r.routes = append(r.routes, rt)
+	}
+	return r, errors.Join(errs...)
+}
+
+// unknownWriters 检查规则引用的写入器名称是否都存在
+func unknownWriters(rule RouteRule, names []string) error {
+	var unknown []string
+	for _, want := range rule.Writers {
+		found := false
+		for _, name := range names {
+			if name == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, want)
+		}
+	}
+	if unknown != nil {
+		return fmt.Errorf("unknown writers %q", unknown)
+	}
+	return nil
+}
+
+func compileRoute(rule RouteRule, names []string) (route, error) {
+	rt := route{cont: rule.Continue}
+	if len(rule.Levels) > 0 {
+		rt.levels = map[string]bool{}
+		for _, lvl := range rule.Levels {
+			if _, ok := levelPriority[lvl]; !ok {
+				return rt, fmt.Errorf("unknown level %q", lvl)
+			}
+			rt.levels[lvl] = true
+		}
+	}
+	var err error
+	if rt.labels, err = compileAnchored(rule.Labels); err != nil {
+		return rt, err
+	}
+	if rt.fields, err = compileAnchored(rule.Fields); err != nil {
+		return rt, err
+	}
+	for _, want := range rule.Writers {
+		for i, name := range names {
+			if name == want {
+				rt.writers = append(rt.writers, i)
+			}
+		}
+	}
+	return rt, nil
+}
+
+// compileAnchored 编译为完整匹配的正则
+func compileAnchored(m map[string]string) (map[string]*regexp.Regexp, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]*regexp.Regexp, len(m))
+	for k, expr := range m {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp for %q: %w", k, err)
+		}
+		out[k] = re
+	}
+	return out, nil
+}
+
+func (rt *route) match(entry *LogEntry) bool {
+	if rt.levels != nil && !rt.levels[entry.Level] {
+		return false
+	}
+	for k, re := range rt.labels {
+		if !re.MatchString(entry.Labels[k]) {
+			return false
+		}
+	}
+	for k, re := range rt.fields {
+		v := ""
+		if fv, ok := entry.Fields[k]; ok {
+			v = fieldString(fv)
+		}
+		if !re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// targets 返回日志应分发到的写入器下标，nil表示分发到所有写入器
+func (r *router) targets(entry *LogEntry) []int {
+	if r == nil {
+		return nil
+	}
+	var out []int
+	matched := false
+	seen := map[int]bool{}
+	for i := range r.routes {
+		rt := &r.routes[i]
+		if !rt.match(entry) {
+			continue
+		}
+		matched = true
+		for _, w := range rt.writers {
+			if !seen[w] {
+				seen[w] = true
+				out = append(out, w)
+			}
+		}
+		if !rt.cont {
+			break
+		}
+	}
+	if !matched {
+		return nil
+	}
+	if out == nil {
+		out = []int{}
+	}
+	return out
+}
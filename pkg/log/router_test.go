@@ -0,0 +1,103 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoutingRules(t *testing.T) {
+	audit, local, loki, hook := NewRingWriter(10), NewRingWriter(10), NewRingWriter(10), NewRingWriter(10)
+	Init(Config{
+		Level: "debug",
+		Writers: map[string]Writer{
+			"audit": audit, "local": local, "loki": loki, "webhook": hook,
+		},
+		Routes: []RouteRule{
+			{Fields: map[string]string{"audit": "true"}, Writers: []string{"audit"}},
+			{Levels: []string{"debug"}, Writers: []string{"local"}},
+			{Levels: []string{"error"}, Writers: []string{"loki"}, Continue: true},
+			{Levels: []string{"error"}, Labels: map[string]string{"env": "prod|staging"}, Writers: []string{"webhook"}},
+			{Fields: map[string]string{"drop": ".+"}},
+			{Writers: []string{"local", "loki"}}, // 兜底规则
+		},
+		Labels: map[string]string{"env": "prod"},
+	})
+	defer Init(Config{})
+
+	Info("login", NewField("user", "alice"), NewField("audit", true))
+	Debug("cache miss")
+	Error("db down")
+	Info("noisy", NewField("drop", "yes"))
+	Info("plain")
+
+	count := func(rw *RingWriter) int { return rw.Len() }
+	if count(audit) != 1 || audit.Entries(RingFilter{})[0].Message != "login" {
+		t.Errorf("audit writer got %d entries", count(audit))
+	}
+	if count(local) != 2 || count(loki) != 2 || count(hook) != 1 {
+		t.Errorf("local=%d loki=%d webhook=%d, want 2, 2, 1", count(local), count(loki), count(hook))
+	}
+	if got := loki.Entries(RingFilter{MinLevel: "error"}); len(got) != 1 {
+		t.Errorf("loki should receive the error entry")
+	}
+	if got := hook.Entries(RingFilter{MinLevel: "error"}); len(got) != 1 {
+		t.Errorf("webhook should receive the error entry via continue")
+	}
+	for _, rw := range []*RingWriter{audit, local, loki, hook} {
+		if len(rw.Entries(RingFilter{Contains: "noisy"})) != 0 {
+			t.Errorf("rule without writers should drop the entry")
+		}
+	}
+
+	// 未命中任何规则的日志分发到所有写入器
+	Init(Config{Writers: map[string]Writer{"audit": audit, "local": local}, Routes: []RouteRule{{Levels: []string{"debug"}, Writers: []string{"local"}}}})
+	Warn("unrouted")
+	if count(audit) != 2 || count(local) != 3 {
+		t.Errorf("unmatched entry not sent to all writers: audit=%d local=%d", count(audit), count(local))
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	if err := ValidateRoutes([]RouteRule{{Labels: map[string]string{"service": "api|worker"}}}); err != nil {
+		t.Errorf("valid rule rejected: %v", err)
+	}
+	if err := ValidateRoutes([]RouteRule{{Fields: map[string]string{"x": "("}}}); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+	if err := ValidateRoutes([]RouteRule{{Levels: []string{"fatal"}}}); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestRoutesUnknownWriters(t *testing.T) {
+	audit, loki := NewRingWriter(10), NewRingWriter(10)
+	Init(Config{
+		Writers: map[string]Writer{"audit": audit, "loki": loki},
+		Routes: []RouteRule{
+			{Fields: map[string]string{"x": "("}, Writers: []string{"audit"}},
+			{Fields: map[string]string{"audit": "true"}, Writers: []string{"audti"}},
+		},
+	})
+	defer Init(Config{})
+
+	// 目标写入器均不存在时丢弃，不分发到其他写入器
+	Info("login", NewField("audit", true))
+	if audit.Len() != 0 || loki.Len() != 0 {
+		t.Errorf("audit=%d loki=%d, want 0, 0", audit.Len(), loki.Len())
+	}
+	Info("other")
+	if audit.Len() != 1 || loki.Len() != 1 {
+		t.Errorf("unmatched entry: audit=%d loki=%d, want 1, 1", audit.Len(), loki.Len())
+	}
+	err := InitError()
+	if err == nil || !strings.Contains(err.Error(), "rule 0") || !strings.Contains(err.Error(), `"audti"`) {
+		t.Errorf("InitError = %v", err)
+	}
+
+	if err := ValidateRoutes([]RouteRule{{Writers: []string{"audti"}}}, "audit", "loki"); err == nil {
+		t.Error("expected error for unknown writer")
+	}
+	if err := ValidateRoutes([]RouteRule{{Writers: []string{"audit"}}}, "audit", "loki"); err != nil {
+		t.Errorf("valid rule rejected: %v", err)
+	}
+}
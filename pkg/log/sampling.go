@@ -0,0 +1,215 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+var metricSampledDrops = newCounterVec("log_sampled_dropped_total",
+	"Entries dropped by sampling or deduplication.", "level", "reason")
+
+// SamplingRule 一个级别的采样规则
+// 每个周期内相同级别与Message的日志先保留First条，之后每Thereafter条保留一条；
+// Thereafter为0时丢弃前First条之后的全部日志
+type SamplingRule struct {
+	First      int // 每周期先保留的条数
+	Thereafter int // 之后的保留间隔
+}
+
+// SamplerConfig 采样处理器配置
+// Interval: 计数周期，默认1秒
+// First/Thereafter: 默认规则，均为0时为100与100
+// Levels: 按级别覆盖默认规则，First<=0表示该级别不采样（如error全部保留）
+type SamplerConfig struct {
+	Interval   time.Duration           // 计数周期
+	First      int                     // 每周期先保留的条数
+	Thereafter int                     // 之后的保留间隔
+	Levels     map[string]SamplingRule // 按级别覆盖
+}
+
+// Sampler 采样处理器，实现Processor接口，用于抑制热点循环中的重复日志
+type Sampler struct {
+	interval time.Duration
+	rule     SamplingRule
+	levels   map[string]SamplingRule
+	now      func() time.Time
+
+	mu       sync.Mutex
+	windowAt time.Time
+	counts   map[string]int
+}
+
+// NewSampler 创建采样处理器
+func NewSampler(c SamplerConfig) *Sampler {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.First == 0 && c.Thereafter == 0 {
+		c.First, c.Thereafter = 100, 100
+	}
+	return &Sampler{
+		interval: c.Interval,
+		rule:     SamplingRule{First: c.First, Thereafter: c.Thereafter},
+		levels:   c.Levels,
+		now:      time.Now,
+		counts:   map[string]int{},
+	}
+}
+
+// Process 实现Processor接口
+func (s *Sampler) Process(entry *LogEntry) (*LogEntry, bool) {
+	rule, ok := s.levels[entry.Level]
+	if !ok {
+		rule = s.rule
+	}
+	if rule.First <= 0 {
+		return entry, true
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.windowAt) >= s.interval {
+		// 新周期整体清空计数，内存不随不同Message的数量累积
+		s.windowAt = now
+		s.counts = map[string]int{}
+	}
+	key := entry.Level + "\x00" + entry.Message
+	s.counts[key]++
+	n := s.counts[key]
+	s.mu.Unlock()
+
+	if n <= rule.First || (rule.Thereafter > 0 && (n-rule.First)%rule.Thereafter == 0) {
+		return entry, true
+	}
+	metricSampledDrops.inc(entry.Level, "sampled")
+	return nil, false
+}
+
+// DedupConfig 去重处理器配置
+// Window: 去重窗口，默认10秒
+// Levels: 参与去重的级别，为空时全部级别
+type DedupConfig struct {
+	Window time.Duration // 去重窗口
+	Levels []string      // 参与去重的级别
+}
+
+// Deduper 去重处理器，实现Processor接口
+// 窗口内第一条日志立即输出，之后相同级别与Message的日志被合并，
+// 窗口结束时输出一条带repeat_count字段（被合并的条数）的汇总日志，字段取自最后一条重复日志
+type Deduper struct {
+	window time.Duration
+	levels map[string]bool
+
+	mu      sync.Mutex
+	pending map[string]*dedupState
+	emit    func(*LogEntry)
+}
+
+// dedupState 一个Message在当前窗口内的状态
+type dedupState struct {
+	last  *LogEntry
+	count int
+}
+
+// NewDeduper 创建去重处理器
+func NewDeduper(c DedupConfig) *Deduper {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	d := &Deduper{window: c.Window, pending: map[string]*dedupState{}}
+	if len(c.Levels) > 0 {
+		d.levels = map[string]bool{}
+		for _, lvl := range c.Levels {
+			d.levels[lvl] = true
+		}
+	}
+	return d
+}
+
+// setEmit 设置汇总日志的输出函数，由Init按处理器所在的链注入
+func (d *Deduper) setEmit(emit func(*LogEntry)) {
+	d.mu.Lock()
+	d.emit = emit
+	d.mu.Unlock()
+}
+
+// Process 实现Processor接口
+func (d *Deduper) Process(entry *LogEntry) (*LogEntry, bool) {
+	if d.levels != nil && !d.levels[entry.Level] {
+		return entry, true
+	}
+	key := entry.Level + "\x00" + entry.Message
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st, ok := d.pending[key]
+	if !ok {
+		st = &dedupState{}
+		d.pending[key] = st
+		time.AfterFunc(d.window, func() { d.flushKey(key, st) })
+		return entry, true
+	}
+	st.last = entry.Clone()
+	st.count++
+	metricSampledDrops.inc(entry.Level, "dedup")
+	return nil, false
+}
+
+// flushKey 结束st对应的窗口，有重复时输出汇总日志；窗口已提前结束时忽略
+func (d *Deduper) flushKey(key string, st *dedupState) {
+	d.mu.Lock()
+	if d.pending[key] != st {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	emit := d.emit
+	d.mu.Unlock()
+
+	if st.count == 0 || emit == nil {
+		return
+	}
+	summary := st.last
+	if summary.Fields == nil {
+		summary.Fields = map[string]interface{}{}
+	}
+	summary.Fields["repeat_count"] = st.count
+	emit(summary)
+}
+
+// flushPending 立即结束所有窗口并输出汇总日志，Flush与Shutdown时调用
+func (d *Deduper) flushPending() {
+	d.mu.Lock()
+	pending := make(map[string]*dedupState, len(d.pending))
+	for k, st := range d.pending {
+		pending[k] = st
+	}
+	d.mu.Unlock()
+	for k, st := range pending {
+		d.flushKey(k, st)
+	}
+}
+
+// emitter 需要在处理器链之外异步输出日志的处理器
+type emitter interface {
+	setEmit(emit func(*LogEntry))
+}
+
+// wireEmitters 为链中的emitter注入输出函数，输出的日志继续执行其后的处理器再交给sink
+// sink在持有mu的情况下调用
+func wireEmitters(chain []Processor, sink func(*LogEntry)) {
+	for i, p := range chain {
+		e, ok := p.(emitter)
+		if !ok {
+			continue
+		}
+		rest := chain[i+1:]
+		e.setEmit(func(entry *LogEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			if entry, ok := runProcessors(rest, entry); ok {
+				sink(entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(SamplerConfig{
+		First:      3,
+		Thereafter: 5,
+		Levels:     map[string]SamplingRule{"error": {}},
+	})
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	kept := func(level, msg string, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if _, ok := s.Process(&LogEntry{Level: level, Message: msg}); ok {
+				count++
+			}
+		}
+		return count
+	}
+
+	// 3条 + 第8、13、18条
+	if got := kept("warn", "hot loop", 20); got != 6 {
+		t.Errorf("warn kept = %d, want 6", got)
+	}
+	// 不同Message与级别分别计数
+	if got := kept("info", "hot loop", 3); got != 3 {
+		t.Errorf("info kept = %d, want 3", got)
+	}
+	if got := kept("error", "hot loop", 50); got != 50 {
+		t.Errorf("error kept = %d, want 50 (sampling disabled)", got)
+	}
+
+	now = now.Add(time.Second)
+	if got := kept("warn", "hot loop", 3); got != 3 {
+		t.Errorf("new interval kept = %d, want 3", got)
+	}
+}
+
+func TestDeduper(t *testing.T) {
+	d := NewDeduper(DedupConfig{Window: 50 * time.Millisecond, Levels: []string{"warn"}})
+	summaries := make(chan *LogEntry, 4)
+	d.setEmit(func(e *LogEntry) { summaries <- e })
+
+	for i := 0; i < 5; i++ {
+		_, ok := d.Process(&LogEntry{Level: "warn", Message: "disk slow", Fields: map[string]interface{}{"i": i}})
+		if ok != (i == 0) {
+			t.Fatalf("entry %d passed = %v", i, ok)
+		}
+	}
+	if _, ok := d.Process(&LogEntry{Level: "info", Message: "disk slow"}); !ok {
+		t.Error("levels outside Levels must pass")
+	}
+	if _, ok := d.Process(&LogEntry{Level: "info", Message: "disk slow"}); !ok {
+		t.Error("levels outside Levels must not be deduplicated")
+	}
+
+	select {
+	case s := <-summaries:
+		if s.Fields["repeat_count"] != 4 || s.Fields["i"] != 4 || s.Message != "disk slow" {
+			t.Errorf("summary = %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no summary emitted")
+	}
+
+	// 窗口结束后重新开始
+	if _, ok := d.Process(&LogEntry{Level: "warn", Message: "disk slow"}); !ok {
+		t.Error("first entry of a new window must pass")
+	}
+}
+
+func TestDedupInInit(t *testing.T) {
+	ring := NewRingWriter(10)
+	Init(Config{
+		Writers:    map[string]Writer{"ring": ring},
+		Dedup:      &DedupConfig{Window: 50 * time.Millisecond},
+		Processors: []Processor{AddFields(map[string]interface{}{"host": "h1"})},
+	})
+	defer Init(Config{})
+
+	for i := 0; i < 10; i++ {
+		Warn("retrying")
+	}
+	if ring.Len() != 1 {
+		t.Fatalf("entries = %d, want 1 before the window ends", ring.Len())
+	}
+	deadline := time.Now().Add(time.Second)
+	for ring.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	entries := ring.Entries(RingFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("entries = %d, want 2", len(entries))
+	}
+	summary := entries[1]
+	if summary.Fields["repeat_count"] != 9 || summary.Fields["host"] != "h1" {
+		t.Errorf("summary fields = %v", summary.Fields)
+	}
+}
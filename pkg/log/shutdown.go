@@ -0,0 +1,165 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// flusher 支持发送已缓冲日志的写入器
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// contextCloser 关闭时接受ctx的写入器，ctx结束后放弃剩余日志的重试
+type contextCloser interface {
+	closeContext(ctx context.Context) error
+}
+
+// pendingFlusher 持有待输出汇总日志的处理器（去重、限流）
+type pendingFlusher interface {
+	flushPending()
+}
+
+// Flush 输出处理器中待输出的汇总日志，并将所有写入器中缓冲的日志发送出去，直到完成或ctx结束
+func Flush(ctx context.Context) error {
+	flushProcessors()
+
+	mu.Lock()
+	ws := append([]Writer(nil), loggers...)
+	names := append([]string(nil), loggerNames...)
+	mu.Unlock()
+
+	return forEachWriter(ctx, ws, names, func(ctx context.Context, w Writer) error {
+		if f, ok := w.(flusher); ok {
+			return f.Flush(ctx)
+		}
+		return nil
+	})
+}
+
+// Shutdown 停止日志模块：输出待输出的汇总日志，排空各写入器的队列并关闭所有写入器
+// ctx结束时不再等待，返回ctx的错误；之后的日志调用被忽略，可再次调用Init重新初始化
+func Shutdown(ctx context.Context) error {
+	flushProcessors()
+
+	mu.Lock()
+	ws, names := loggers, loggerNames
+	loggers, loggerNames, loggerProcs = nil, nil, nil
+	routes = nil
+	mu.Unlock()
+
+	return closeWriters(ctx, ws, names)
+}
+
+// flushProcessors 输出全局与写入器处理器链中待输出的汇总日志
+// 汇总日志经emit写入，emit需要获取mu，因此在mu之外调用
+func flushProcessors() {
+	mu.Lock()
+	var ps []pendingFlusher
+	for _, chain := range append([][]Processor{cfg.Processors}, loggerProcs...) {
+		for _, p := range chain {
+			if pf, ok := p.(pendingFlusher); ok {
+				ps = append(ps, pf)
+			}
+		}
+	}
+	mu.Unlock()
+
+	for _, pf := range ps {
+		pf.flushPending()
+	}
+}
+
+// closeWriters 发送写入器中缓冲的日志后关闭写入器
+func closeWriters(ctx context.Context, ws []Writer, names []string) error {
+	return forEachWriter(ctx, ws, names, func(ctx context.Context, w Writer) error {
+		var err error
+		if f, ok := w.(flusher); ok {
+			err = f.Flush(ctx)
+		}
+		switch c := w.(type) {
+		case contextCloser:
+			if cerr := c.closeContext(ctx); err == nil {
+				err = cerr
+			}
+		case io.Closer:
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	})
+}
+
+// forEachWriter 并发地对每个写入器执行fn，ctx结束时不再等待尚未完成的写入器
+func forEachWriter(ctx context.Context, ws []Writer, names []string, fn func(context.Context, Writer) error) error {
+	errs := make([]error, len(ws))
+	var wg sync.WaitGroup
+	for i, w := range ws {
+		wg.Add(1)
+		go func(i int, w Writer) {
+			defer wg.Done()
+			if err := fn(ctx, w); err != nil {
+				errs[i] = fmt.Errorf("failed to shut down writer %s: %w", names[i], err)
+			}
+		}(i, w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return errors.Join(errs...)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeFileWriters 同步关闭ws中的文件写入器，返回其余写入器及其名称
+func closeFileWriters(ws []Writer, names []string) ([]Writer, []string, error) {
+	var rest []Writer
+	var restNames []string
+	var errs []error
+	for i, w := range ws {
+		fw, ok := w.(*FileWriter)
+		if !ok {
+			rest = append(rest, w)
+			restNames = append(restNames, names[i])
+			continue
+		}
+		if err := fw.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close writer %s: %w", names[i], err))
+		}
+	}
+	return rest, restNames, errors.Join(errs...)
+}
+
+// replacedWriters 返回old中不再出现在current中的写入器及其名称
+// Config.Writers中的同一实例可以在多次Init之间复用，不会被关闭
+func replacedWriters(old []Writer, oldNames []string, current []Writer) ([]Writer, []string) {
+	var ws []Writer
+	var names []string
+	for i, w := range old {
+		reused := false
+		if reflect.TypeOf(w).Comparable() {
+			for _, c := range current {
+				if reflect.TypeOf(c).Comparable() && c == w {
+					reused = true
+					break
+				}
+			}
+		}
+		if !reused {
+			ws = append(ws, w)
+			names = append(names, oldNames[i])
+		}
+	}
+	return ws, names
+}
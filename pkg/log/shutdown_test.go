@@ -0,0 +1,242 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeRecorder 记录Close调用的写入器，block非nil时Close阻塞到其关闭
+type closeRecorder struct {
+	closed atomic.Int32
+	block  chan struct{}
+}
+
+func (w *closeRecorder) Write(*LogEntry) error { return nil }
+
+func (w *closeRecorder) Close() error {
+	if w.block != nil {
+		<-w.block
+	}
+	w.closed.Add(1)
+	return nil
+}
+
+func TestShutdownDrainsWriters(t *testing.T) {
+	srv, bodies := lokiTestServer(t)
+	lw := NewLokiWriterWithConfig(LokiWriterConfig{URL: srv.URL, Batch: BatchConfig{Interval: time.Hour}})
+	ring, rec := NewRingWriter(10), &closeRecorder{}
+	Init(Config{
+		Writers: map[string]Writer{"loki": lw, "ring": ring, "rec": rec},
+		Dedup:   &DedupConfig{Window: time.Hour},
+	})
+	defer Init(Config{})
+
+	Warn("disk slow")
+	Warn("disk slow")
+	Warn("disk slow")
+	Info("done")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 去重的汇总日志在关闭前输出，Loki队列中的日志被推送
+	var payload struct {
+		Streams []struct {
+			Values [][]string `json:"values"`
+		} `json:"streams"`
+	}
+	select {
+	case b := <-bodies:
+		json.Unmarshal(b, &payload)
+	default:
+		t.Fatal("pending Loki batch was not pushed")
+	}
+	n := 0
+	for _, s := range payload.Streams {
+		n += len(s.Values)
+	}
+	if n != 3 {
+		t.Errorf("pushed %d entries, want 3", n)
+	}
+	entries := ring.Entries(RingFilter{})
+	if len(entries) != 3 || entries[2].Fields["repeat_count"] != 2 {
+		t.Errorf("ring entries = %+v", entries)
+	}
+	if rec.closed.Load() != 1 {
+		t.Error("writer not closed")
+	}
+	if err := lw.Write(&LogEntry{}); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("write after shutdown = %v", err)
+	}
+
+	// 关闭后的日志被忽略
+	Info("ignored")
+	if ring.Len() != 3 {
+		t.Error("logging after Shutdown must be a no-op")
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	rec := &closeRecorder{block: make(chan struct{})}
+	defer close(rec.block)
+	Init(Config{Writers: map[string]Writer{"slow": rec}})
+	defer Init(Config{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want deadline exceeded", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	srv, bodies := lokiTestServer(t)
+	lw := NewLokiWriterWithConfig(LokiWriterConfig{URL: srv.URL, Batch: BatchConfig{Interval: time.Hour}})
+	Init(Config{Writers: map[string]Writer{"loki": lw}})
+	defer Init(Config{})
+
+	Info("a")
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-bodies:
+	default:
+		t.Fatal("Flush did not push the pending batch")
+	}
+	if err := lw.Write(&LogEntry{}); err != nil {
+		t.Errorf("writer must stay open after Flush: %v", err)
+	}
+}
+
+func TestInitFlushesReplacedProcessors(t *testing.T) {
+	old, limited, current := NewRingWriter(10), NewRingWriter(10), NewRingWriter(10)
+	Init(Config{
+		Writers:    map[string]Writer{"old": old, "limited": limited},
+		Dedup:      &DedupConfig{Window: time.Hour},
+		RateLimits: map[string]RateLimitConfig{"limited": {Lines: 1, BurstLines: 2, ReportInterval: time.Hour}},
+	})
+	Warn("disk slow")
+	Warn("disk slow")
+	Info("a")
+	Info("b")
+	Init(Config{Writers: map[string]Writer{"current": current}})
+	defer Init(Config{})
+
+	// 去重汇总与限流报告都经旧链写入旧写入器，汇总日志同样受限流
+	if entries := old.Entries(RingFilter{}); len(entries) != 4 || entries[3].Fields["repeat_count"] != 1 {
+		t.Errorf("old writer entries = %+v", entries)
+	}
+	if entries := limited.Entries(RingFilter{}); len(entries) != 3 || entries[2].Fields["dropped_entries"] != 2 {
+		t.Errorf("limited writer entries = %+v", entries)
+	}
+	if current.Len() != 0 {
+		t.Errorf("new writer got entries of the old config: %+v", current.Entries(RingFilter{}))
+	}
+}
+
+func TestInitClosesReplacedWriters(t *testing.T) {
+	replaced, reused := &closeRecorder{}, &closeRecorder{}
+	Init(Config{Writers: map[string]Writer{"a": replaced, "b": reused}})
+	Init(Config{Writers: map[string]Writer{"b": reused}})
+	defer Init(Config{})
+
+	deadline := time.Now().Add(time.Second)
+	for replaced.closed.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if replaced.closed.Load() != 1 {
+		t.Error("replaced writer not closed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if reused.closed.Load() != 0 {
+		t.Error("writer reused by the new config must stay open")
+	}
+}
+
+func TestReInitAuditFileVerifies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	key := []byte("secret")
+	c := Config{Files: []FileWriterConfig{{Path: path, Audit: &AuditConfig{HMACKey: key}}}}
+
+	Init(c)
+	Info("first")
+	// 重新Init时旧写入器须在新写入器接续哈希链之前写完检查点
+	Init(c)
+	Info("second")
+	Init(Config{})
+
+	if err := VerifyFileWithKeys(path, VerifyKeys{HMACKey: key}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatcherCloseKeepsAcceptedEntries(t *testing.T) {
+	for round := 0; round < 50; round++ {
+		var pushed atomic.Int64
+		b := newBatcher("test", BatchConfig{Size: 10}, RetryConfig{}, func(batch []*LogEntry) error {
+			pushed.Add(int64(len(batch)))
+			return nil
+		})
+		var accepted atomic.Int64
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for b.enqueue(&LogEntry{}) == nil {
+					accepted.Add(1)
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		b.close(context.Background())
+		wg.Wait()
+		// enqueue返回nil的日志都必须被发送
+		if accepted.Load() != pushed.Load() {
+			t.Fatalf("accepted %d entries but pushed %d", accepted.Load(), pushed.Load())
+		}
+	}
+}
+
+func TestShutdownAbortsRetryBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	lw := NewLokiWriterWithConfig(LokiWriterConfig{
+		URL:   srv.URL,
+		Batch: BatchConfig{Size: 1},
+		Retry: RetryConfig{MaxRetries: 3, Backoff: time.Hour},
+	})
+	Init(Config{Writers: map[string]Writer{"loki": lw}})
+	defer Init(Config{})
+
+	before := metricDropped.get("loki", "shutdown")
+	Info("unreachable")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want deadline exceeded", err)
+	}
+	// 超时后后台协程放弃退避等待并退出，不再遗留重试
+	select {
+	case <-lw.batcher.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("retry goroutine still running after Shutdown returned")
+	}
+	if got := metricDropped.get("loki", "shutdown") - before; got != 1 {
+		t.Errorf("shutdown drops = %v, want 1", got)
+	}
+}
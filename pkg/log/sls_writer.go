@@ -0,0 +1,278 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SLSWriterConfig 阿里云日志服务（SLS）写入器配置
+// Endpoint: 地域接入点，如"cn-hangzhou.log.aliyuncs.com"
+// BaseURL: 覆盖请求地址（如本地测试服务），为空时为https://{Project}.{Endpoint}
+// Labels: 附加的日志标签（LogTag），与每条日志的Labels合并
+// Batch/Retry: 与LokiWriter相同的批量发送与重试配置
+type SLSWriterConfig struct {
+	Endpoint        string            // 接入点
+	Project         string            // 项目
+	Logstore        string            // 日志库
+	AccessKeyID     string            // AccessKey ID
+	AccessKeySecret string            // AccessKey Secret
+	SecurityToken   string            // STS临时凭证Token，可选
+	Topic           string            // 日志主题
+	Source          string            // 日志来源，默认主机名
+	BaseURL         string            // 请求地址覆盖
+	Labels          map[string]string // 附加标签
+	Batch           BatchConfig       // 批量配置
+	Retry           RetryConfig       // 重试配置
+	Timeout         time.Duration     // 单次请求超时，默认10秒
+}
+
+// SLSWriter 阿里云SLS写入器，实现Writer接口
+// 日志按标签集合分组为LogGroup，以protobuf+lz4调用PutLogs接口
+
+type SLSWriter struct {
+	cfg        SLSWriterConfig
+	baseURL    string
+	host       string
+	httpClient *http.Client
+	batcher    *batcher
+}
+
+// NewSLSWriter 创建SLS写入器
+func NewSLSWriter(c SLSWriterConfig) (*SLSWriter, error) {
+	if c.Project == "" || c.Logstore == "" {
+		return nil, fmt.Errorf("sls project and logstore are required")
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.Source == "" {
+		c.Source = hostname()
+	}
+
+	base := c.BaseURL
+	if base == "" {
+		if c.Endpoint == "" {
+			return nil, fmt.Errorf("sls endpoint is required")
+		}
+		ep := strings.TrimPrefix(strings.TrimPrefix(c.Endpoint, "https://"), "http://")
+		base = "https://" + c.Project + "." + ep
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sls url %q: %w", base, err)
+	}
+
+	sw := &SLSWriter{
+		cfg:        c,
+		baseURL:    strings.TrimSuffix(base, "/"),
+		host:       u.Host,
+		httpClient: &http.Client{Timeout: c.Timeout},
+	}
+	sw.batcher = newBatcher("sls", c.Batch, c.Retry, sw.push)
+	return sw, nil
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台批量推送
+func (sw *SLSWriter) Write(entry *LogEntry) error {
+	return sw.batcher.enqueue(entry)
+}
+
+// Flush 推送队列中已有的日志
+func (sw *SLSWriter) Flush(ctx context.Context) error {
+	return sw.batcher.flush(ctx)
+}
+
+// Close 推送剩余日志并停止后台协程
+func (sw *SLSWriter) Close() error {
+	return sw.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (sw *SLSWriter) closeContext(ctx context.Context) error {
+	return sw.batcher.close(ctx)
+}
+
+// push 按标签集合分组，每组一个LogGroup请求
+// 某组失败时返回partialError，重试只重发该组及之后尚未发送的组
+func (sw *SLSWriter) push(batch []*LogEntry) error {
+	groups := map[string][]*LogEntry{}
+	tags := map[string]map[string]string{}
+	var order []string
+	for _, entry := range batch {
+		labels := map[string]string{}
+		for k, v := range sw.cfg.Labels {
+			labels[k] = v
+		}
+		for k, v := range entry.Labels {
+			labels[k] = v
+		}
+		key := labelsKey(labels)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+			tags[key] = labels
+		}
+		groups[key] = append(groups[key], entry)
+	}
+
+	for i, key := range order {
+		body := sw.encodeLogGroup(groups[key], tags[key])
+		if err := sw.putLogs(body); err != nil {
+			var failed []*LogEntry
+			for _, k := range order[i:] {
+				failed = append(failed, groups[k]...)
+			}
+			return &partialError{failed: failed, err: err}
+		}
+	}
+	return nil
+}
+
+// encodeLogGroup 编码LogGroup：Logs=1, Topic=3, Source=4, LogTags=6
+func (sw *SLSWriter) encodeLogGroup(entries []*LogEntry, tags map[string]string) []byte {
+	var group []byte
+	for _, entry := range entries {
+		group = appendProtoBytes(group, 1, encodeSLSLog(entry))
+	}
+	if sw.cfg.Topic != "" {
+		group = appendProtoString(group, 3, sw.cfg.Topic)
+	}
+	group = appendProtoString(group, 4, sw.cfg.Source)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var tag []byte
+		tag = appendProtoString(tag, 1, k)
+		tag = appendProtoString(tag, 2, tags[k])
+		group = appendProtoBytes(group, 6, tag)
+	}
+	return group
+}
+
+// encodeSLSLog 编码Log：Time=1, Contents=2(Key=1, Value=2)
+// 级别、消息、调用位置与Fields均作为Contents
+func encodeSLSLog(entry *LogEntry) []byte {
+	var rec []byte
+	rec = appendProtoVarint(rec, 1, uint64(uint32(entry.Time)))
+	addContent := func(k, v string) {
+		var c []byte
+		c = appendProtoString(c, 1, k)
+		c = appendProtoString(c, 2, v)
+		rec = appendProtoBytes(rec, 2, c)
+	}
+	addContent("level", entry.Level)
+	addContent("message", entry.Message)
+	if entry.Caller != "" {
+		addContent("caller", entry.Caller)
+	}
+	for _, k := range sortedKeys(entry.Fields) {
+		addContent(k, fieldString(entry.Fields[k]))
+	}
+	return rec
+}
+
+// fieldString 字段值转为字符串，非字符串值使用JSON表示
+func fieldString(v interface{}) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case fmt.Stringer:
+		return tv.String()
+	case error:
+		return tv.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// putLogs 调用PutLogs接口
+func (sw *SLSWriter) putLogs(raw []byte) error {
+	body := lz4CompressBlock(raw)
+	resource := "/logstores/" + sw.cfg.Logstore + "/shards/lb"
+
+	req, err := http.NewRequest(http.MethodPost, sw.baseURL+resource, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+	sum := md5.Sum(body)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-MD5", strings.ToUpper(hex.EncodeToString(sum[:])))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-log-apiversion", "0.6.0")
+	req.Header.Set("x-log-signaturemethod", "hmac-sha1")
+	req.Header.Set("x-log-bodyrawsize", strconv.Itoa(len(raw)))
+	req.Header.Set("x-log-compresstype", "lz4")
+	if sw.cfg.SecurityToken != "" {
+		req.Header.Set("x-acs-security-token", sw.cfg.SecurityToken)
+	}
+	req.Host = sw.host
+	req.Header.Set("Authorization", "LOG "+sw.cfg.AccessKeyID+":"+slsSignature(sw.cfg.AccessKeySecret, req, resource))
+
+	resp, err := sw.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to SLS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("SLS returned status code %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	metricBytesSent.add(float64(len(body)), "sls")
+	return nil
+}
+
+// slsSignature 计算SLS请求签名
+// base64(hmac-sha1(secret, VERB\nCONTENT-MD5\nCONTENT-TYPE\nDATE\nCanonicalizedLOGHeaders\nCanonicalizedResource))
+func slsSignature(secret string, req *http.Request, resource string) string {
+	var headers []string
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-log-") || strings.HasPrefix(lk, "x-acs-") {
+			headers = append(headers, lk+":"+req.Header.Get(k))
+		}
+	}
+	sort.Strings(headers)
+
+	toSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		req.Header.Get("Date"),
+		strings.Join(headers, "\n"),
+		resource,
+	}, "\n")
+
+	m := hmac.New(sha1.New, []byte(secret))
+	m.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(m.Sum(nil))
+}
+
+func hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
@@ -0,0 +1,214 @@
+package log
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lz4DecompressBlock 测试用的LZ4块解压
+func lz4DecompressBlock(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+	readLen := func(i int, n int) (int, int) {
+		if n != 15 {
+			return n, i
+		}
+		for {
+			b := src[i]
+			i++
+			n += int(b)
+			if b != 255 {
+				return n, i
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		var litLen int
+		litLen, i = readLen(i, int(token>>4))
+		dst = append(dst, src[i:i+litLen]...)
+		i += litLen
+		if i >= len(src) {
+			break
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, errors.New("invalid offset")
+		}
+		var mlen int
+		mlen, i = readLen(i, int(token&15))
+		mlen += lz4MinMatch
+		start := len(dst) - offset
+		for j := 0; j < mlen; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if len(dst) != size {
+		return nil, errors.New("size mismatch")
+	}
+	return dst, nil
+}
+
+// protoFields 测试用的protobuf解析，返回各字段的原始值（varint按uint64）
+func protoFields(b []byte) map[int][]interface{} {
+	out := map[int][]interface{}{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		b = b[n:]
+		field, wire := int(tag>>3), tag&7
+		switch wire {
+		case protoVarint:
+			v, n := binary.Uvarint(b)
+			b = b[n:]
+			out[field] = append(out[field], v)
+		case protoBytes:
+			l, n := binary.Uvarint(b)
+			b = b[n:]
+			out[field] = append(out[field], b[:l])
+			b = b[l:]
+		case protoFixed64:
+			out[field] = append(out[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		default:
+			return out
+		}
+	}
+	return out
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("short"),
+		[]byte(strings.Repeat("abcdefgh", 1000)),
+		[]byte(strings.Repeat(`{"level":"info","msg":"hello world"}`, 50) + "tail-literals"),
+	}
+	for _, in := range inputs {
+		out, err := lz4DecompressBlock(lz4CompressBlock(in), len(in))
+		if err != nil || string(out) != string(in) {
+			t.Fatalf("lz4 round trip failed for %d bytes: %v", len(in), err)
+		}
+	}
+}
+
+func TestSLSWriterPutLogs(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	var groups [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			// 第一次返回服务端错误，验证重试
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path != "/logstores/app/shards/lb" || r.Header.Get("x-log-compresstype") != "lz4" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		resource := r.URL.Path
+		if want := "LOG ak:" + slsSignature("sk", r, resource); r.Header.Get("Authorization") != want {
+			t.Errorf("bad signature %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		size, _ := strconv.Atoi(r.Header.Get("x-log-bodyrawsize"))
+		raw, err := lz4DecompressBlock(body, size)
+		if err != nil {
+			t.Errorf("decompress: %v", err)
+		}
+		groups = append(groups, raw)
+	}))
+	defer srv.Close()
+
+	sw, err := NewSLSWriter(SLSWriterConfig{
+		Project: "proj", Logstore: "app", AccessKeyID: "ak", AccessKeySecret: "sk",
+		BaseURL: srv.URL, Source: "host1", Labels: map[string]string{"env": "test"},
+		Retry: RetryConfig{Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = sw.Write(&LogEntry{Level: "info", Message: "a", Labels: map[string]string{"service": "api"}, Fields: map[string]interface{}{"n": 1}, Time: 1700000000})
+	_ = sw.Write(&LogEntry{Level: "warn", Message: "b", Labels: map[string]string{"service": "api"}, Time: 1700000001})
+	_ = sw.Write(&LogEntry{Level: "info", Message: "c", Labels: map[string]string{"service": "worker"}, Time: 1700000002})
+	if err := sw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = sw.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(groups) != 2 {
+		t.Fatalf("got %d log groups, want 2 (one per label set)", len(groups))
+	}
+	g := protoFields(groups[0])
+	if len(g[1]) != 2 || string(g[4][0].([]byte)) != "host1" || len(g[6]) != 2 {
+		t.Fatalf("unexpected log group %v", g)
+	}
+	tag := protoFields(g[6][0].([]byte))
+	if string(tag[1][0].([]byte)) != "env" || string(tag[2][0].([]byte)) != "test" {
+		t.Errorf("unexpected tag %v", tag)
+	}
+	rec := protoFields(g[1][0].([]byte))
+	if rec[1][0].(uint64) != 1700000000 || len(rec[2]) != 3 {
+		t.Fatalf("unexpected log %v", rec)
+	}
+	content := protoFields(rec[2][2].([]byte))
+	if string(content[1][0].([]byte)) != "n" || string(content[2][0].([]byte)) != "1" {
+		t.Errorf("unexpected field content %v", content)
+	}
+}
+
+func TestSLSWriterRetriesOnlyFailedGroups(t *testing.T) {
+	var mu sync.Mutex
+	sent := map[string]int{}
+	failed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		size, _ := strconv.Atoi(r.Header.Get("x-log-bodyrawsize"))
+		raw, _ := lz4DecompressBlock(body, size)
+		service := "api"
+		if strings.Contains(string(raw), "worker") {
+			service = "worker"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if service == "worker" && !failed {
+			// 第二组第一次失败
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		sent[service]++
+	}))
+	defer srv.Close()
+
+	sw, err := NewSLSWriter(SLSWriterConfig{
+		Project: "proj", Logstore: "app", AccessKeyID: "ak", AccessKeySecret: "sk",
+		BaseURL: srv.URL, Retry: RetryConfig{Backoff: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = sw.Write(&LogEntry{Level: "info", Message: "a", Labels: map[string]string{"service": "api"}, Time: 1700000000})
+	_ = sw.Write(&LogEntry{Level: "info", Message: "b", Labels: map[string]string{"service": "worker"}, Time: 1700000001})
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sent["api"] != 1 || sent["worker"] != 1 {
+		t.Errorf("sent = %v, want each group exactly once", sent)
+	}
+}
@@ -0,0 +1,278 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslog消息格式
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// syslogFacilities syslog设施名称到编号
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverities 日志级别到syslog严重性
+var syslogSeverities = map[string]int{
+	"debug": 7,
+	"info":  6,
+	"warn":  4,
+	"error": 3,
+}
+
+// SyslogWriterConfig syslog写入器配置
+// Network: "unix"（默认，本地套接字）、"udp"或"tcp"，TCP使用RFC 6587八位组计数分帧，
+// 本地流式套接字（unix数据报不可用时回退）以换行分隔消息
+// Address: 默认"/dev/log"
+// Format: 消息格式，默认RFC 5424，Fields编码为结构化数据
+// Facility: 设施名称，默认"user"
+// AppName/Hostname: 默认为进程名与主机名
+// SDID: 结构化数据ID，默认"fields@32473"
+type SyslogWriterConfig struct {
+	Network  string        // 网络类型
+	Address  string        // 地址
+	Format   string        // 消息格式
+	Facility string        // 设施
+	AppName  string        // 应用名
+	Hostname string        // 主机名
+	SDID     string        // 结构化数据ID
+	Batch    BatchConfig   // 批量配置
+	Retry    RetryConfig   // 重试配置
+	Timeout  time.Duration // 连接与写超时
+}
+
+// SyslogWriter syslog写入器，实现Writer接口
+// 日志进入队列后由后台协程发送，连接断开后自动重连
+
+type SyslogWriter struct {
+	cfg      SyslogWriterConfig
+	facility int
+	conn     *reconnectConn
+	batcher  *batcher
+}
+
+// NewSyslogWriter 创建syslog写入器，连接在首次写入时建立
+func NewSyslogWriter(c SyslogWriterConfig) (*SyslogWriter, error) {
+	if c.Network == "" {
+		c.Network = "unix"
+	}
+	if c.Address == "" {
+		c.Address = "/dev/log"
+	}
+	if c.Format == "" {
+		c.Format = SyslogRFC5424
+	}
+	if c.Format != SyslogRFC5424 && c.Format != SyslogRFC3164 {
+		return nil, fmt.Errorf("unknown syslog format %q", c.Format)
+	}
+	if c.Facility == "" {
+		c.Facility = "user"
+	}
+	facility, ok := syslogFacilities[c.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", c.Facility)
+	}
+	if c.AppName == "" {
+		c.AppName = filepath.Base(os.Args[0])
+	}
+	if c.Hostname == "" {
+		c.Hostname, _ = os.Hostname()
+	}
+	if c.SDID == "" {
+		c.SDID = "fields@32473"
+	}
+
+	sw := &SyslogWriter{cfg: c, facility: facility}
+	switch c.Network {
+	case "unix":
+		// /dev/log通常为数据报套接字
+		sw.conn = newReconnectConn(c.Address, c.Timeout, "unixgram", "unix")
+	case "udp", "unixgram":
+		sw.conn = newReconnectConn(c.Address, c.Timeout, c.Network)
+	case "tcp":
+		sw.conn = newReconnectConn(c.Address, c.Timeout, c.Network)
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", c.Network)
+	}
+	sw.batcher = newBatcher("syslog", c.Batch, c.Retry, sw.push)
+	return sw, nil
+}
+
+// Write 实现Writer接口，日志进入发送队列后由后台发送到syslog
+func (sw *SyslogWriter) Write(entry *LogEntry) error {
+	return sw.batcher.enqueue(entry)
+}
+
+// Flush 发送队列中已有的日志
+func (sw *SyslogWriter) Flush(ctx context.Context) error {
+	return sw.batcher.flush(ctx)
+}
+
+// Close 发送剩余日志，停止后台协程并关闭连接
+func (sw *SyslogWriter) Close() error {
+	return sw.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (sw *SyslogWriter) closeContext(ctx context.Context) error {
+	err := sw.batcher.close(ctx)
+	if cerr := sw.conn.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// push 逐条发送，失败时返回partialError，重试从失败的日志开始
+func (sw *SyslogWriter) push(batch []*LogEntry) error {
+	for i, entry := range batch {
+		var msg []byte
+		if sw.cfg.Format == SyslogRFC3164 {
+			msg = sw.formatRFC3164(entry)
+		} else {
+			msg = sw.formatRFC5424(entry)
+		}
+		size := 0
+		err := sw.conn.do(func(conn net.Conn) error {
+			// 分帧方式取决于实际建立的连接类型
+			framed := syslogFrame(msg, sw.conn.network)
+			size = len(framed)
+			_, err := conn.Write(framed)
+			return err
+		})
+		if err != nil {
+			return &partialError{failed: batch[i:], err: fmt.Errorf("failed to write to syslog: %w", err)}
+		}
+		metricBytesSent.add(float64(size), "syslog")
+	}
+	return nil
+}
+
+// syslogFrame 流式连接需要分帧：TCP使用RFC 6587八位组计数，本地流式套接字以换行结尾
+func syslogFrame(msg []byte, network string) []byte {
+	switch network {
+	case "tcp":
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	case "unix":
+		return append(msg, '\n')
+	}
+	return msg
+}
+
+// priority 计算PRI值
+func (sw *SyslogWriter) priority(level string) int {
+	sev, ok := syslogSeverities[level]
+	if !ok {
+		sev = syslogSeverities["info"]
+	}
+	return sw.facility*8 + sev
+}
+
+// formatRFC5424 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (sw *SyslogWriter) formatRFC5424(entry *LogEntry) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - ",
+		sw.priority(entry.Level),
+		time.Unix(entry.Time, 0).Format(time.RFC3339),
+		syslogHeaderValue(sw.cfg.Hostname, 255),
+		syslogHeaderValue(sw.cfg.AppName, 48),
+		os.Getpid(),
+	)
+
+	if len(entry.Fields) == 0 {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteByte('[')
+		buf.WriteString(sw.cfg.SDID)
+		for _, k := range sortedKeys(entry.Fields) {
+			buf.WriteByte(' ')
+			buf.WriteString(sdParamName(k))
+			buf.WriteString(`="`)
+			buf.WriteString(sdEscape(fmt.Sprint(entry.Fields[k])))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+
+	if entry.Message != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(entry.Message)
+	}
+	return buf.Bytes()
+}
+
+// formatRFC3164 <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG key=value...
+func (sw *SyslogWriter) formatRFC3164(entry *LogEntry) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>%s %s %s[%d]: %s",
+		sw.priority(entry.Level),
+		time.Unix(entry.Time, 0).Format(time.Stamp),
+		syslogHeaderValue(sw.cfg.Hostname, 255),
+		syslogHeaderValue(sw.cfg.AppName, 32),
+		os.Getpid(),
+		entry.Message,
+	)
+	for _, k := range sortedKeys(entry.Fields) {
+		buf.WriteString(" " + k + "=" + formatFieldValue(entry.Fields[k]))
+	}
+	return buf.Bytes()
+}
+
+// syslogHeaderValue 头部字段只能是可打印ASCII且不含空格，空值写"-"
+func syslogHeaderValue(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// sdParamName 结构化数据参数名：最多32个可打印ASCII，不含'=', ' ', ']', '"'
+func sdParamName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "_"
+	}
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+// sdEscape 转义结构化数据参数值中的'"', '\'和']'
+func sdEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '"' || r == '\\' || r == ']' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
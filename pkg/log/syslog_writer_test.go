@@ -0,0 +1,135 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readOctetFrame 读取一条RFC 6587八位组计数分帧的消息
+func readOctetFrame(r *bufio.Reader) (string, error) {
+	n, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, size)
+	_, err = io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+func TestSyslogWriterTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	msgs := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			msg, err := readOctetFrame(r)
+			// 每条消息后断开，迫使写入器重连
+			conn.Close()
+			if err == nil {
+				msgs <- msg
+			}
+		}
+	}()
+
+	sw, err := NewSyslogWriter(SyslogWriterConfig{Network: "tcp", Address: ln.Addr().String(), Facility: "local0", AppName: "svc", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+
+	entry := &LogEntry{Level: "error", Message: "disk full", Fields: map[string]interface{}{"path": `/var/"x"]`}, Time: 1700000000}
+	if err := sw.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := sw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := <-msgs
+	// local0(16)*8 + err(3) = 131
+	if !strings.HasPrefix(got, "<131>1 ") || !strings.HasSuffix(got, ` svc `+strconv.Itoa(os.Getpid())+` - [fields@32473 path="/var/\"x\"\]"] disk full`) {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	// 服务端已断开，写入器应在写入前发现并重连，日志不丢失
+	_ = sw.Write(&LogEntry{Level: "warn", Message: "again", Time: 1700000000})
+	if err := sw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-msgs:
+		if !strings.HasPrefix(got, "<132>1 ") {
+			t.Errorf("unexpected message after reconnect %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("message written after the peer closed was lost")
+	}
+}
+
+func TestSyslogWriterUnixStreamFallback(t *testing.T) {
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := dir + "/log.sock"
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+
+	// 流式套接字上unixgram连接失败，回退到unix后每条消息以换行分隔
+	sw, err := NewSyslogWriter(SyslogWriterConfig{Address: path, AppName: "svc", Hostname: "host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sw.Close()
+	_ = sw.Write(&LogEntry{Level: "info", Message: "one", Time: 1700000000})
+	_ = sw.Write(&LogEntry{Level: "info", Message: "two", Time: 1700000000})
+	if err := sw.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"one", "two"} {
+		select {
+		case got := <-lines:
+			if !strings.HasPrefix(got, "<14>1 ") || !strings.HasSuffix(got, " - "+want) {
+				t.Errorf("unexpected message %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %q not received as a separate line", want)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Webhook消息预设
+const (
+	WebhookSlack    = "slack"
+	WebhookDingTalk = "dingtalk"
+	WebhookFeishu   = "feishu"
+	WebhookJSON     = "json"
+)
+
+// webhookPresets 各预设的请求体模板
+var webhookPresets = map[string]string{
+	WebhookSlack:    `{"text":{{json .Text}}}`,
+	WebhookDingTalk: `{"msgtype":"text","text":{"content":{{json .Text}}}}`,
+	WebhookFeishu:   `{"msg_type":"text","content":{"text":{{json .Text}}}}`,
+	WebhookJSON:     `{{json .}}`,
+}
+
+// WebhookWriterConfig 告警Webhook写入器配置
+// Preset: 请求体预设，"slack"、"dingtalk"、"feishu"或"json"（默认）；
+// dingtalk与feishu在HTTP 200的响应体中以错误码报告失败，限流错误会重试，其余错误不再重试
+// Template: 自定义text/template请求体，非空时覆盖Preset，数据为WebhookMessage，可用函数json
+// MinLevel: 触发通知的最低级别，默认"error"
+// DedupeWindow: 同级别同消息在窗口内只通知一次，默认5分钟
+// RateLimit: 每分钟最多发送的通知数，超出的日志被丢弃并计入下一条通知，默认10
+// Batch: Batch.Interval为突发聚合窗口（默认10秒），窗口内的日志合并为一条摘要通知
+type WebhookWriterConfig struct {
+	URL          string            // 通知地址
+	Preset       string            // 请求体预设
+	Template     string            // 自定义请求体模板
+	Headers      map[string]string // 附加请求头
+	MinLevel     string            // 最低触发级别
+	DedupeWindow time.Duration     // 去重窗口
+	RateLimit    int               // 每分钟最多通知数
+	MaxEntries   int               // 摘要中最多列出的条目数，默认20
+	Batch        BatchConfig       // 批量配置
+	Retry        RetryConfig       // 重试配置
+	Timeout      time.Duration     // 单次请求超时，默认10秒
+}
+
+// WebhookMessage 一条通知，作为请求体模板的数据
+type WebhookMessage struct {
+	Title      string         `json:"title"`      // 标题，单条时为消息本身，多条时为摘要
+	Text       string         `json:"text"`       // 完整的纯文本内容
+	Entries    []WebhookEntry `json:"entries"`    // 去重后的日志
+	Total      int            `json:"total"`      // 包含重复在内的日志总数
+	Omitted    int            `json:"omitted"`    // 超出MaxEntries未列出的条目数
+	Suppressed int            `json:"suppressed"` // 自上次通知以来因去重或限流未通知的日志数
+}
+
+// WebhookEntry 通知中的一条日志
+type WebhookEntry struct {
+	Level   string                 `json:"level"`
+	Message string                 `json:"msg"`
+	Caller  string                 `json:"caller,omitempty"`
+	Time    time.Time              `json:"time"`
+	Labels  map[string]string      `json:"labels,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Count   int                    `json:"count"` // 本次窗口内重复次数
+}
+
+// WebhookWriter 将高级别日志以Webhook通知发出，实现Writer接口
+// 同一聚合窗口内的日志合并为一条摘要，后台发送不阻塞调用方
+
+type WebhookWriter struct {
+	cfg        WebhookWriterConfig
+	tmpl       *template.Template
+	httpClient *http.Client
+	batcher    *batcher
+
+	// 以下状态只在batcher的后台协程中访问
+	notified   map[string]time.Time // 去重键到最近一次通知时间
+	sent       []time.Time          // 最近一分钟的发送时间
+	suppressed int
+}
+
+// NewWebhookWriter 创建Webhook写入器
+func NewWebhookWriter(c WebhookWriterConfig) (*WebhookWriter, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("webhook url is required")
+	}
+	if c.MinLevel == "" {
+		c.MinLevel = "error"
+	}
+	if _, ok := levelPriority[c.MinLevel]; !ok {
+		return nil, fmt.Errorf("unknown level %q for webhook", c.MinLevel)
+	}
+	if c.Template == "" {
+		if c.Preset == "" {
+			c.Preset = WebhookJSON
+		}
+		preset, ok := webhookPresets[c.Preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown webhook preset %q", c.Preset)
+		}
+		c.Template = preset
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": webhookJSON}).Parse(c.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+	}
+	if c.DedupeWindow <= 0 {
+		c.DedupeWindow = 5 * time.Minute
+	}
+	if c.RateLimit <= 0 {
+		c.RateLimit = 10
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 20
+	}
+	if c.Batch.Interval <= 0 {
+		c.Batch.Interval = 10 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+
+	ww := &WebhookWriter{
+		cfg:        c,
+		tmpl:       tmpl,
+		httpClient: &http.Client{Timeout: c.Timeout},
+		notified:   map[string]time.Time{},
+	}
+	ww.batcher = newBatcher("webhook", c.Batch, c.Retry, ww.push)
+	return ww, nil
+}
+
+func webhookJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// Write 实现Writer接口，低于MinLevel的日志被忽略
+func (ww *WebhookWriter) Write(entry *LogEntry) error {
+	if levelPriority[entry.Level] < levelPriority[ww.cfg.MinLevel] {
+		return nil
+	}
+	return ww.batcher.enqueue(entry)
+}
+
+// Flush 立即发送聚合窗口中的日志
+func (ww *WebhookWriter) Flush(ctx context.Context) error {
+	return ww.batcher.flush(ctx)
+}
+
+// Close 发送剩余日志并停止后台协程
+func (ww *WebhookWriter) Close() error {
+	return ww.closeContext(context.Background())
+}
+
+// closeContext 同Close，ctx结束时放弃剩余日志的重试
+func (ww *WebhookWriter) closeContext(ctx context.Context) error {
+	return ww.batcher.close(ctx)
+}
+
+// push 去重、限流后将一批日志合并为一条通知
+// 重试时会以同一批日志再次调用，因此状态只在发送成功后更新
+func (ww *WebhookWriter) push(batch []*LogEntry) error {
+	now := time.Now()
+	var entries []WebhookEntry
+	index := map[string]int{}
+	suppressed := 0
+	for _, entry := range batch {
+		key := entry.Level + "\x00" + entry.Message
+		if t, ok := ww.notified[key]; ok && now.Sub(t) < ww.cfg.DedupeWindow {
+			suppressed++
+			continue
+		}
+		if i, ok := index[key]; ok {
+			entries[i].Count++
+			continue
+		}
+		index[key] = len(entries)
+		entries = append(entries, WebhookEntry{
+			Level:   entry.Level,
+			Message: entry.Message,
+			Caller:  entry.Caller,
+			Time:    time.Unix(entry.Time, 0),
+			Labels:  entry.Labels,
+			Fields:  entry.Fields,
+			Count:   1,
+		})
+	}
+	if len(entries) == 0 {
+		ww.suppressed += suppressed
+		return nil
+	}
+
+	// 限流：最近一分钟的通知数达到上限时丢弃本批
+	recent := ww.sent[:0]
+	for _, t := range ww.sent {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	ww.sent = recent
+	if len(ww.sent) >= ww.cfg.RateLimit {
+		ww.suppressed += len(batch)
+		return nil
+	}
+
+	msg := ww.message(entries, len(batch)-suppressed, ww.suppressed+suppressed)
+	if err := ww.post(msg); err != nil {
+		return err
+	}
+
+	ww.sent = append(ww.sent, now)
+	ww.suppressed = 0
+	for key := range index {
+		ww.notified[key] = now
+	}
+	for key, t := range ww.notified {
+		if now.Sub(t) >= ww.cfg.DedupeWindow {
+			delete(ww.notified, key)
+		}
+	}
+	return nil
+}
+
+// message 生成通知内容
+func (ww *WebhookWriter) message(entries []WebhookEntry, total, suppressed int) *WebhookMessage {
+	msg := &WebhookMessage{Total: total, Suppressed: suppressed}
+	if len(entries) > ww.cfg.MaxEntries {
+		msg.Omitted = len(entries) - ww.cfg.MaxEntries
+		entries = entries[:ww.cfg.MaxEntries]
+	}
+	msg.Entries = entries
+
+	if total == 1 {
+		msg.Title = fmt.Sprintf("[%s] %s", strings.ToUpper(entries[0].Level), entries[0].Message)
+	} else {
+		msg.Title = fmt.Sprintf("%d log events (%d distinct)", total, len(entries)+msg.Omitted)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg.Title)
+	for _, e := range entries {
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "%s [%s] %s", e.Time.Format("2006-01-02 15:04:05"), strings.ToUpper(e.Level), e.Message)
+		if e.Count > 1 {
+			fmt.Fprintf(&sb, " (x%d)", e.Count)
+		}
+		for _, k := range sortedStringKeys(e.Labels) {
+			sb.WriteString(" " + k + "=" + e.Labels[k])
+		}
+		for _, k := range sortedKeys(e.Fields) {
+			sb.WriteString(" " + k + "=" + formatFieldValue(e.Fields[k]))
+		}
+		if e.Caller != "" {
+			sb.WriteString(" caller=" + e.Caller)
+		}
+	}
+	if msg.Omitted > 0 {
+		fmt.Fprintf(&sb, "\n... and %d more", msg.Omitted)
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(&sb, "\n(%d repeated or rate-limited events suppressed)", suppressed)
+	}
+	msg.Text = sb.String()
+	return msg
+}
+
+// post 渲染模板并发送
+func (ww *WebhookWriter) post(msg *WebhookMessage) error {
+	var body bytes.Buffer
+	if err := ww.tmpl.Execute(&body, msg); err != nil {
+		return permanent(fmt.Errorf("failed to render webhook template: %w", err))
+	}
+	size := body.Len()
+	req, err := http.NewRequest(http.MethodPost, ww.cfg.URL, &body)
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ww.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := ww.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("webhook returned status code %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		if !retryableStatus(resp.StatusCode) {
+			return permanent(err)
+		}
+		return err
+	}
+	if ww.cfg.Preset == WebhookDingTalk || ww.cfg.Preset == WebhookFeishu {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err := webhookResponseError(ww.cfg.Preset, data); err != nil {
+			return err
+		}
+	}
+	metricBytesSent.add(float64(size), "webhook")
+	return nil
+}
+
+// 钉钉与飞书的限流错误码，可以重试
+var webhookRetryableCodes = map[string]map[int]bool{
+	WebhookDingTalk: {130101: true},
+	WebhookFeishu:   {9499: true, 11232: true},
+}
+
+// webhookResponseError 解析钉钉（errcode/errmsg）与飞书（code/msg）的响应体，错误码非0时返回错误
+// 响应体不是JSON或没有错误码字段时视为成功
+func webhookResponseError(preset string, body []byte) error {
+	var r struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil
+	}
+	code, msg := r.Code, r.Msg
+	if preset == WebhookDingTalk {
+		code, msg = r.ErrCode, r.ErrMsg
+	}
+	if code == nil || *code == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%s webhook returned error code %d: %s", preset, *code, msg)
+	if !webhookRetryableCodes[preset][*code] {
+		return permanent(err)
+	}
+	return err
+}
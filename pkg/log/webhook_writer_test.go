@@ -0,0 +1,155 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookWriterDigestAndDedupe(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Errorf("invalid json body %s", b)
+		}
+		mu.Lock()
+		bodies = append(bodies, m)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ww, err := NewWebhookWriter(WebhookWriterConfig{URL: srv.URL, Preset: WebhookDingTalk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ww.Close()
+
+	_ = ww.Write(&LogEntry{Level: "info", Message: "ignored", Time: 1700000000})
+	_ = ww.Write(&LogEntry{Level: "error", Message: "db down", Fields: map[string]interface{}{"host": "db1"}, Time: 1700000000})
+	_ = ww.Write(&LogEntry{Level: "error", Message: "db down", Time: 1700000001})
+	_ = ww.Write(&LogEntry{Level: "error", Message: "disk full", Time: 1700000002})
+	if err := ww.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 去重窗口内重复的消息不再通知
+	_ = ww.Write(&LogEntry{Level: "error", Message: "db down", Time: 1700000003})
+	_ = ww.Flush(context.Background())
+	_ = ww.Write(&LogEntry{Level: "error", Message: "new problem", Time: 1700000004})
+	_ = ww.Flush(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(bodies))
+	}
+	text := bodies[0]["text"].(map[string]interface{})["content"].(string)
+	if bodies[0]["msgtype"] != "text" || !strings.HasPrefix(text, "3 log events (2 distinct)") {
+		t.Errorf("unexpected digest %q", text)
+	}
+	if !strings.Contains(text, "db down (x2) host=db1") || strings.Contains(text, "ignored") {
+		t.Errorf("unexpected digest lines %q", text)
+	}
+	text = bodies[1]["text"].(map[string]interface{})["content"].(string)
+	if !strings.HasPrefix(text, "[ERROR] new problem") || !strings.Contains(text, "(1 repeated or rate-limited events suppressed)") {
+		t.Errorf("unexpected second notification %q", text)
+	}
+}
+
+func TestWebhookWriterRateLimitAndTemplate(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ww, err := NewWebhookWriter(WebhookWriterConfig{
+		URL: srv.URL, RateLimit: 1,
+		Template: `{"alert":{{json .Title}},"n":{{.Total}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ww.Close()
+
+	for _, m := range []string{"a", "b"} {
+		_ = ww.Write(&LogEntry{Level: "error", Message: m})
+		_ = ww.Flush(context.Background())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || bodies[0] != `{"alert":"[ERROR] a","n":1}` {
+		t.Errorf("unexpected notifications %q", bodies)
+	}
+	if _, err := NewWebhookWriter(WebhookWriterConfig{URL: srv.URL, Preset: "teams"}); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+}
+
+func TestWebhookWriterBodyErrorCodes(t *testing.T) {
+	// 钉钉先返回限流错误码，重试后成功
+	var mu sync.Mutex
+	replies := []string{`{"errcode":130101,"errmsg":"send too fast"}`, `{"errcode":0,"errmsg":"ok"}`}
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, replies[calls%len(replies)])
+		calls++
+	}))
+	defer srv.Close()
+
+	ww, err := NewWebhookWriter(WebhookWriterConfig{URL: srv.URL, Preset: WebhookDingTalk, Retry: RetryConfig{Backoff: time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ww.Close()
+	_ = ww.Write(&LogEntry{Level: "error", Message: "db down"})
+	_ = ww.Flush(context.Background())
+	mu.Lock()
+	if calls != 2 {
+		t.Errorf("dingtalk calls = %d, want 2 (rate limited, then retried)", calls)
+	}
+	mu.Unlock()
+
+	// 飞书返回非限流错误码时不再重试，计入rejected
+	feishu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		io.WriteString(w, `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`)
+	}))
+	defer feishu.Close()
+	mu.Lock()
+	calls = 0
+	mu.Unlock()
+	before := metricDropped.get("webhook", "rejected")
+	fw, err := NewWebhookWriter(WebhookWriterConfig{URL: feishu.URL, Preset: WebhookFeishu, Retry: RetryConfig{Backoff: time.Millisecond}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+	_ = fw.Write(&LogEntry{Level: "error", Message: "db down"})
+	_ = fw.Flush(context.Background())
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("feishu calls = %d, want 1", calls)
+	}
+	if got := metricDropped.get("webhook", "rejected") - before; got != 1 {
+		t.Errorf("rejected drops = %v, want 1", got)
+	}
+}
@@ -1,5 +1,7 @@
 package log
 
+import "reflect"
+
 // Writer 日志写入器接口
 // 实现本地文件、Loki等多种写入方式
 
@@ -7,13 +9,77 @@ type Writer interface {
 	Write(entry *LogEntry) error
 }
 
+// encoderSetter 支持设置编码器的写入器，Init时传入Config.Encoder
+type encoderSetter interface {
+	SetEncoder(enc *Encoder)
+}
+
 // LogEntry 日志条目结构体
 // 包含时间、级别、消息、标签、字段等
 
 type LogEntry struct {
-	Level   string                 // 日志级别
-	Message string                 // 日志内容
-	Labels  map[string]string      // 标签
-	Fields  map[string]interface{} // 额外字段
-	Time    int64                  // 时间戳（秒）
+	Level   string                 `json:"level"`            // 日志级别
+	Message string                 `json:"msg"`              // 日志内容
+	Caller  string                 `json:"caller,omitempty"` // 调用位置（文件:行号）
+	Labels  map[string]string      `json:"labels,omitempty"` // 标签
+	Fields  map[string]interface{} `json:"fields,omitempty"` // 额外字段
+	Time    int64                  `json:"ts"`               // 时间戳（秒）
+}
+
+// Clone 复制日志条目，Labels与Fields为新的map，字段值中的map与切片递归复制
+// 指针与结构体中的引用仍与原条目共享，处理器应替换而不是修改这类值
+func (e *LogEntry) Clone() *LogEntry {
+	c := *e
+	if e.Labels != nil {
+		c.Labels = make(map[string]string, len(e.Labels))
+		for k, v := range e.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if e.Fields != nil {
+		c.Fields = make(map[string]interface{}, len(e.Fields))
+		for k, v := range e.Fields {
+			c.Fields[k] = cloneValue(v)
+		}
+	}
+	return &c
+}
+
+// cloneValue 递归复制值中的map与切片，其他值原样返回
+func cloneValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, int, int64, float64:
+		return v
+	}
+	return cloneReflect(reflect.ValueOf(v)).Interface()
+}
+
+func cloneReflect(rv reflect.Value) reflect.Value {
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+		return reflect.ValueOf(cloneValue(rv.Interface()))
+	case reflect.Map:
+		if rv.IsNil() {
+			return rv
+		}
+		out := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		it := rv.MapRange()
+		for it.Next() {
+			out.SetMapIndex(it.Key(), cloneReflect(it.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if rv.IsNil() {
+			return rv
+		}
+		out := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out.Index(i).Set(cloneReflect(rv.Index(i)))
+		}
+		return out
+	}
+	return rv
 }